@@ -0,0 +1,445 @@
+// Command cdk-templates is the CDK app of the Go templates. It synthesizes the
+// template stacks selected by the "stacks" context value or flag for the
+// "env" environment:
+//
+//	cdk synth -c stacks=api,cache -c env=dev
+//	go run ./cmd/cdk-templates -stacks api,cache -env dev
+//
+// Flags take precedence over context values. The project, author and the
+// settings of each stack are read from config/config.json and its
+// config.<env>.json overlay.
+//
+// The Lambda functions are packaged from sample-code (api, websocket) and
+// lambda (vpc-endpoints) relative to the working directory.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/jsii-runtime-go"
+
+	"cdk-template/templates"
+)
+
+// Settings are the stack settings of config.json and its overlays, next to the
+// author, project and tag policy of templates.Config.
+type Settings struct {
+	templates.Config
+	// Account and Region default to the CDK CLI's CDK_DEFAULT_ACCOUNT and
+	// CDK_DEFAULT_REGION.
+	Account      string `json:"account"`
+	Region       string `json:"region"`
+	IsProduction bool   `json:"isProduction"`
+	// Vpc is the shared VPC of the cache, database and vpc-endpoints stacks,
+	// which create their own VPC when it is empty.
+	Vpc          VpcSettings        `json:"vpc"`
+	API          *APISettings       `json:"api"`
+	WebSocket    *APISettings       `json:"websocket"`
+	Cache        *CacheSettings     `json:"cache"`
+	Database     *CacheSettings     `json:"database"`
+	DNS          *DNSSettings       `json:"dns"`
+	VPCEndpoints *EndpointsSettings `json:"vpcEndpoints"`
+	Network      *NetworkSettings   `json:"network"`
+}
+
+type VpcSettings struct {
+	VpcId   string `json:"vpcId"`
+	VpcName string `json:"vpcName"`
+}
+
+func (settings VpcSettings) existingVpc() templates.ExistingVpcProps {
+	return templates.ExistingVpcProps{VpcId: settings.VpcId, VpcName: settings.VpcName}
+}
+
+type APISettings struct {
+	DomainName       string `json:"domainName"`
+	ApiDomainName    string `json:"apiDomainName"`
+	CertificateArn   string `json:"certificateArn"`
+	HostedZoneId     string `json:"hostedZoneId"`
+	SampleCodeBucket string `json:"sampleCodeBucket"`
+	// APIType is "" for a REST API or "http".
+	APIType string `json:"apiType"`
+	// JWTAuthorizer protects the routes of HTTP APIs.
+	JWTAuthorizer *struct {
+		Issuer   string   `json:"issuer"`
+		Audience []string `json:"audience"`
+	} `json:"jwtAuthorizer"`
+	// AllowUnauthenticated makes an HTTP API without JWTAuthorizer public.
+	AllowUnauthenticated bool `json:"allowUnauthenticated"`
+}
+
+type CacheSettings struct {
+	CacheNodeType  string `json:"cacheNodeType"`
+	CertificateArn string `json:"certificateArn"`
+}
+
+type DNSSettings struct {
+	ZoneName string `json:"zoneName"`
+	// HostedZoneId imports the zone instead of creating it.
+	HostedZoneId string `json:"hostedZoneId"`
+	ARecords     []struct {
+		RecordName  string   `json:"recordName"`
+		IPAddresses []string `json:"ipAddresses"`
+	} `json:"aRecords"`
+}
+
+type NetworkSettings struct {
+	Cidr              string `json:"cidr"`
+	IpamPoolId        string `json:"ipamPoolId"`
+	IpamNetmaskLength int    `json:"ipamNetmaskLength"`
+	AZs               int    `json:"azs"`
+	SubnetMasks       struct {
+		Public   int `json:"public"`
+		Private  int `json:"private"`
+		Isolated int `json:"isolated"`
+	} `json:"subnetMasks"`
+	// NAT is "" for a gateway per AZ, "single", "instance" or "none".
+	NAT             string           `json:"nat"`
+	DualStack       bool             `json:"dualStack"`
+	ParameterPrefix string           `json:"parameterPrefix"`
+	FlowLogs        *FlowLogSettings `json:"flowLogs"`
+	NetworkACLs     []struct {
+		SubnetGroup string   `json:"subnetGroup"`
+		DenyCIDRs   []string `json:"denyCidrs"`
+		Rules       []struct {
+			Deny         bool     `json:"deny"`
+			Egress       bool     `json:"egress"`
+			Ports        []int    `json:"ports"`
+			CIDRs        []string `json:"cidrs"`
+			SubnetGroups []string `json:"subnetGroups"`
+		} `json:"rules"`
+	} `json:"networkAcls"`
+	TransitGateway *struct {
+		TransitGatewayId         string   `json:"transitGatewayId"`
+		SubnetGroup              string   `json:"subnetGroup"`
+		ApplianceMode            bool     `json:"applianceMode"`
+		AssociationRouteTableId  string   `json:"associationRouteTableId"`
+		PropagationRouteTableIds []string `json:"propagationRouteTableIds"`
+		Routes                   []string `json:"routes"`
+	} `json:"transitGateway"`
+	Peerings []struct {
+		Name        string `json:"name"`
+		PeerVpcId   string `json:"peerVpcId"`
+		PeerCidr    string `json:"peerCidr"`
+		PeerRegion  string `json:"peerRegion"`
+		PeerOwnerId string `json:"peerOwnerId"`
+		PeerRoleArn string `json:"peerRoleArn"`
+	} `json:"peerings"`
+}
+
+type FlowLogSettings struct {
+	// Destination is "" for CloudWatch Logs, "s3" or "firehose".
+	Destination string `json:"destination"`
+	// TrafficType is ALL, ACCEPT or REJECT.
+	TrafficType string   `json:"trafficType"`
+	Fields      []string `json:"fields"`
+	// Retention is a CloudWatch Logs retention, e.g. ONE_MONTH.
+	Retention         string `json:"retention"`
+	KeyPrefix         string `json:"keyPrefix"`
+	ExpirationDays    int    `json:"expirationDays"`
+	PerHourPartitions bool   `json:"perHourPartitions"`
+	DeliveryStreamArn string `json:"deliveryStreamArn"`
+}
+
+type EndpointsSettings struct {
+	LambdaCodePath      string `json:"lambdaCodePath"`
+	SharedSecurityGroup bool   `json:"sharedSecurityGroup"`
+	// Tables, Secrets and Topics name the resources the default endpoints
+	// allow.
+	Tables  []string `json:"tables"`
+	Secrets []string `json:"secrets"`
+	Topics  []string `json:"topics"`
+}
+
+// stackBuilders creates the stack of each template, by the name used in the
+// "stacks" context value.
+var stackBuilders = map[string]func(app awscdk.App, id string, props stackProps) error{
+	"api":           buildAPI,
+	"websocket":     buildWebSocket,
+	"cache":         buildCache,
+	"database":      buildDatabase,
+	"dns":           buildDNS,
+	"vpc-endpoints": buildVPCEndpoints,
+	"network":       buildNetwork,
+}
+
+type stackProps struct {
+	awscdk.StackProps
+	environment string
+	config      *templates.Config
+	settings    *Settings
+}
+
+func main() {
+	defer jsii.Close()
+
+	app := awscdk.NewApp(nil)
+	if err := run(app); err != nil {
+		fmt.Fprintln(os.Stderr, "cdk-templates:", err)
+		os.Exit(1)
+	}
+
+	app.Synth(nil)
+}
+
+func run(app awscdk.App) error {
+	environment := flag.String("env", "", "environment to synthesize, overrides the env context value (default dev)")
+	stacks := flag.String("stacks", "", "comma separated stacks to synthesize, overrides the stacks context value (default api)")
+	configPath := flag.String("config", "../config/config.json", "path to config.json")
+	flag.Parse()
+
+	if *environment == "" {
+		*environment = contextValue(app, "env", "dev")
+	}
+	if *stacks == "" {
+		*stacks = contextValue(app, "stacks", "api")
+	}
+
+	settings := &Settings{
+		Account: os.Getenv("CDK_DEFAULT_ACCOUNT"),
+		Region:  os.Getenv("CDK_DEFAULT_REGION"),
+	}
+	if err := templates.ReadConfigFiles(*configPath, *environment, settings); err != nil {
+		return err
+	}
+	if err := settings.Config.Resolve(); err != nil {
+		return err
+	}
+
+	// Stacks stay environment agnostic when neither is known
+	stackEnvironment := &awscdk.Environment{}
+	if settings.Account != "" {
+		stackEnvironment.Account = jsii.String(settings.Account)
+	}
+	if settings.Region != "" {
+		stackEnvironment.Region = jsii.String(settings.Region)
+	}
+
+	props := stackProps{
+		StackProps:  awscdk.StackProps{Env: stackEnvironment},
+		environment: *environment,
+		config:      &settings.Config,
+		settings:    settings,
+	}
+
+	for _, name := range strings.Split(*stacks, ",") {
+		name = strings.TrimSpace(name)
+		build, ok := stackBuilders[name]
+		if !ok {
+			return fmt.Errorf("unknown stack %q, expected one of %s", name, strings.Join(stackNames(), ", "))
+		}
+		if err := build(app, *environment+"-"+name, props); err != nil {
+			return fmt.Errorf("stack %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func contextValue(app awscdk.App, key string, fallback string) string {
+	if value, ok := app.Node().TryGetContext(jsii.String(key)).(string); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func stackNames() []string {
+	names := make([]string, 0, len(stackBuilders))
+	for name := range stackBuilders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func buildAPI(app awscdk.App, id string, props stackProps) error {
+	settings := props.settings.API
+	if settings == nil {
+		return fmt.Errorf("missing \"api\" settings")
+	}
+
+	templates.NewAPIResources(app, id, &templates.PropsAPIResources{
+		StackProps:           props.StackProps,
+		DomainName:           settings.DomainName,
+		Environment:          props.environment,
+		SampleCodeBucket:     settings.SampleCodeBucket,
+		CertificateArn:       settings.CertificateArn,
+		HostedZoneId:         settings.HostedZoneId,
+		ApiDomainName:        settings.ApiDomainName,
+		IsProduction:         props.settings.IsProduction,
+		Config:               props.config,
+		APIType:              templates.APIType(settings.APIType),
+		JWTAuthorizer:        (*templates.JWTAuthorizerSettings)(settings.JWTAuthorizer),
+		AllowUnauthenticated: settings.AllowUnauthenticated,
+	})
+	return nil
+}
+
+func buildWebSocket(app awscdk.App, id string, props stackProps) error {
+	settings := props.settings.WebSocket
+	if settings == nil {
+		return fmt.Errorf("missing \"websocket\" settings")
+	}
+
+	templates.NewWebSocketAPI(app, id, &templates.PropsWebSocketAPI{
+		StackProps:     props.StackProps,
+		DomainName:     settings.DomainName,
+		Environment:    props.environment,
+		CertificateArn: settings.CertificateArn,
+		HostedZoneId:   settings.HostedZoneId,
+		ApiDomainName:  settings.ApiDomainName,
+		IsProduction:   props.settings.IsProduction,
+		Config:         props.config,
+	})
+	return nil
+}
+
+func buildCache(app awscdk.App, id string, props stackProps) error {
+	settings := props.settings.Cache
+	if settings == nil {
+		settings = &CacheSettings{}
+	}
+
+	stack := awscdk.NewStack(app, jsii.String(id), &props.StackProps)
+	templates.NewElastiCache(stack, "Cache", &templates.ElastiCacheProps{
+		ExistingVpcProps: props.settings.Vpc.existingVpc(),
+		Environment:      props.environment,
+		Config:           props.config,
+		CacheNodeType:    settings.CacheNodeType,
+		CertificateArn:   settings.CertificateArn,
+	})
+	return nil
+}
+
+func buildDatabase(app awscdk.App, id string, props stackProps) error {
+	settings := props.settings.Database
+	if settings == nil {
+		settings = &CacheSettings{}
+	}
+
+	stack := awscdk.NewStack(app, jsii.String(id), &props.StackProps)
+	templates.NewRDSElastiCache(stack, "Database", &templates.RDSElastiCacheProps{
+		ExistingVpcProps: props.settings.Vpc.existingVpc(),
+		Environment:      props.environment,
+		IsProduction:     props.settings.IsProduction,
+		Config:           props.config,
+		CacheNodeType:    settings.CacheNodeType,
+	})
+	return nil
+}
+
+func buildDNS(app awscdk.App, id string, props stackProps) error {
+	settings := props.settings.DNS
+	if settings == nil {
+		return fmt.Errorf("missing \"dns\" settings")
+	}
+
+	stack := awscdk.NewStack(app, jsii.String(id), &props.StackProps)
+
+	zone := templates.HostedZoneProps{ZoneName: settings.ZoneName}
+	if settings.HostedZoneId != "" {
+		zone.Zone = awsroute53.HostedZone_FromHostedZoneAttributes(stack, jsii.String("HostedZone"), &awsroute53.HostedZoneAttributes{
+			HostedZoneId: jsii.String(settings.HostedZoneId),
+			ZoneName:     jsii.String(settings.ZoneName),
+		})
+	} else {
+		zone.Zone = awsroute53.NewHostedZone(stack, jsii.String("HostedZone"), &awsroute53.HostedZoneProps{
+			ZoneName: jsii.String(settings.ZoneName),
+		})
+	}
+
+	for index, record := range settings.ARecords {
+		templates.NewARecord(stack, fmt.Sprintf("ARecord%d", index+1), &templates.ARecordProps{
+			HostedZoneProps: zone,
+			RecordName:      record.RecordName,
+			IPAddresses:     record.IPAddresses,
+		})
+	}
+
+	templates.ApplyStandardTags(stack, props.config, props.environment, settings.ZoneName)
+	return nil
+}
+
+func buildVPCEndpoints(app awscdk.App, id string, props stackProps) error {
+	settings := props.settings.VPCEndpoints
+	if settings == nil {
+		settings = &EndpointsSettings{}
+	}
+
+	templates.NewVPCEndpointStack(app, id, &templates.VPCEndpointStackProps{
+		StackProps:          props.StackProps,
+		ExistingVpcProps:    props.settings.Vpc.existingVpc(),
+		Environment:         props.environment,
+		Config:              props.config,
+		LambdaCodePath:      settings.LambdaCodePath,
+		SharedSecurityGroup: settings.SharedSecurityGroup,
+		Resources: templates.EndpointResources{
+			Tables:  settings.Tables,
+			Secrets: settings.Secrets,
+			Topics:  settings.Topics,
+		},
+	})
+	return nil
+}
+
+func buildNetwork(app awscdk.App, id string, props stackProps) error {
+	settings := props.settings.Network
+	if settings == nil {
+		settings = &NetworkSettings{}
+	}
+
+	var flowLogs *templates.FlowLogSettings
+	if settings.FlowLogs != nil {
+		flowLogs = &templates.FlowLogSettings{
+			Destination:       templates.FlowLogDestination(settings.FlowLogs.Destination),
+			TrafficType:       awsec2.FlowLogTrafficType(settings.FlowLogs.TrafficType),
+			Fields:            settings.FlowLogs.Fields,
+			Retention:         awslogs.RetentionDays(settings.FlowLogs.Retention),
+			KeyPrefix:         settings.FlowLogs.KeyPrefix,
+			ExpirationDays:    settings.FlowLogs.ExpirationDays,
+			PerHourPartitions: settings.FlowLogs.PerHourPartitions,
+			DeliveryStreamArn: settings.FlowLogs.DeliveryStreamArn,
+		}
+	}
+
+	var networkACLs []templates.NetworkACLRuleSet
+	for _, ruleSet := range settings.NetworkACLs {
+		networkACL := templates.NetworkACLRuleSet{SubnetGroup: ruleSet.SubnetGroup, DenyCIDRs: ruleSet.DenyCIDRs}
+		for _, rule := range ruleSet.Rules {
+			networkACL.Rules = append(networkACL.Rules, templates.NetworkACLRule(rule))
+		}
+		networkACLs = append(networkACLs, networkACL)
+	}
+
+	var peerings []templates.VpcPeeringSettings
+	for _, peering := range settings.Peerings {
+		peerings = append(peerings, templates.VpcPeeringSettings(peering))
+	}
+
+	templates.NewNetworkStack(app, id, &templates.NetworkStackProps{
+		StackProps:        props.StackProps,
+		Environment:       props.environment,
+		Config:            props.config,
+		Cidr:              settings.Cidr,
+		IpamPoolId:        settings.IpamPoolId,
+		IpamNetmaskLength: settings.IpamNetmaskLength,
+		AZs:               settings.AZs,
+		SubnetMasks:       templates.SubnetMasks(settings.SubnetMasks),
+		NAT:               templates.NATStrategy(settings.NAT),
+		DualStack:         settings.DualStack,
+		ParameterPrefix:   settings.ParameterPrefix,
+		FlowLogs:          flowLogs,
+		NetworkACLs:       networkACLs,
+		TransitGateway:    (*templates.TransitGatewaySettings)(settings.TransitGateway),
+		Peerings:          peerings,
+	})
+	return nil
+}
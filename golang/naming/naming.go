@@ -0,0 +1,86 @@
+// Package naming builds the physical names of the resources created by the
+// templates from the environment, the project and a component name.
+//
+// Every name is <environment>-<project>-<component...>, cleaned up for the
+// character set of the target service. Names longer than the service allows
+// are truncated and end with a hash of the full name, so they stay unique and
+// do not change between deployments.
+package naming
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// Length of the hash suffix appended to truncated names
+const hashLength = 8
+
+// Service describes the naming rules of an AWS resource type.
+type Service struct {
+	MaxLength int
+	Lowercase bool
+	// Invalid matches the characters replaced with a hyphen.
+	Invalid *regexp.Regexp
+	// Underscores separates the parts with underscores instead of hyphens,
+	// which Athena cannot query without quoting.
+	Underscores bool
+}
+
+var (
+	IAMRole             = Service{MaxLength: 64, Invalid: regexp.MustCompile(`[^\w+=,.@-]`)}
+	IAMPolicy           = Service{MaxLength: 128, Invalid: regexp.MustCompile(`[^\w+=,.@-]`)}
+	LambdaFunction      = Service{MaxLength: 64, Invalid: regexp.MustCompile(`[^\w-]`)}
+	SNSTopic            = Service{MaxLength: 256, Invalid: regexp.MustCompile(`[^\w-]`)}
+	SQSQueue            = Service{MaxLength: 80, Invalid: regexp.MustCompile(`[^\w-]`)}
+	DynamoDBTable       = Service{MaxLength: 255, Invalid: regexp.MustCompile(`[^\w.-]`)}
+	CloudWatchDashboard = Service{MaxLength: 255, Invalid: regexp.MustCompile(`[^\w-]`)}
+	XRaySamplingRule    = Service{MaxLength: 32, Invalid: regexp.MustCompile(`[^\w-]`)}
+	WAF                 = Service{MaxLength: 128, Invalid: regexp.MustCompile(`[^\w-]`)}
+	ElastiCacheCluster  = Service{MaxLength: 40, Lowercase: true, Invalid: regexp.MustCompile(`[^a-z0-9-]`)}
+	ElastiCacheSubnets  = Service{MaxLength: 255, Lowercase: true, Invalid: regexp.MustCompile(`[^a-z0-9-]`)}
+	RDSInstance         = Service{MaxLength: 63, Lowercase: true, Invalid: regexp.MustCompile(`[^a-z0-9-]`)}
+	GlueDatabase        = Service{MaxLength: 255, Lowercase: true, Invalid: regexp.MustCompile(`[^a-z0-9]`), Underscores: true}
+	GlueTable           = Service{MaxLength: 255, Lowercase: true, Invalid: regexp.MustCompile(`[^a-z0-9]`), Underscores: true}
+)
+
+var repeatedHyphens = regexp.MustCompile(`-{2,}`)
+
+// Namer names the resources of one project in one environment.
+type Namer struct {
+	Environment string
+	Project     string
+}
+
+func New(environment string, project string) Namer {
+	return Namer{Environment: environment, Project: project}
+}
+
+// Name returns the physical name of a component for the given service.
+func (namer Namer) Name(service Service, components ...string) string {
+	parts := append([]string{namer.Environment, namer.Project}, components...)
+	return service.Name(strings.Join(parts, "-"))
+}
+
+// Name cleans up name for the service, truncating it if needed.
+func (service Service) Name(name string) string {
+	if service.Lowercase {
+		name = strings.ToLower(name)
+	}
+	name = service.Invalid.ReplaceAllString(name, "-")
+	name = repeatedHyphens.ReplaceAllString(name, "-")
+	name = strings.Trim(name, "-")
+
+	if len(name) > service.MaxLength {
+		sum := sha256.Sum256([]byte(name))
+		suffix := hex.EncodeToString(sum[:])[:hashLength]
+		prefix := strings.TrimRight(name[:service.MaxLength-hashLength-1], "-")
+		name = prefix + "-" + suffix
+	}
+
+	if service.Underscores {
+		name = strings.ReplaceAll(name, "-", "_")
+	}
+	return name
+}
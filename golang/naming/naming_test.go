@@ -0,0 +1,62 @@
+package naming
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+	namer := New("dev", "Template")
+	long := strings.Repeat("logs-", 60)
+
+	tests := []struct {
+		name    string
+		service Service
+		parts   []string
+		want    string
+	}{
+		{"short", LambdaFunction, []string{"api"}, "dev-Template-api"},
+		{"lowercase", RDSInstance, []string{"Orders", "DB"}, "dev-template-orders-db"},
+		{"invalid characters", SNSTopic, []string{"api.example.com", "alarms"}, "dev-Template-api-example-com-alarms"},
+		{"repeated and trailing hyphens", SQSQueue, []string{"orders--", "dead letters!"}, "dev-Template-orders-dead-letters"},
+		{"allowed characters kept", IAMRole, []string{"api.example.com", "role@eu+1"}, "dev-Template-api.example.com-role@eu+1"},
+		{"underscores", GlueDatabase, []string{"Access-Logs"}, "dev_template_access_logs"},
+		{"truncated", XRaySamplingRule, []string{"orders-service", "sampling"}, "dev-Template-orders-ser-eceb1973"},
+		{"truncated at a hyphen", XRaySamplingRule, []string{"orders-ab-sampling-rule"}, "dev-Template-orders-ab-5c9d4cfa"},
+		{"truncated with underscores", GlueTable, []string{long}, "dev_template_" + strings.Repeat("logs_", 46) + "log_198045c2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := namer.Name(test.service, test.parts...)
+			if got != test.want {
+				t.Errorf("Name() = %q, want %q", got, test.want)
+			}
+			if len(got) > test.service.MaxLength {
+				t.Errorf("Name() = %q is longer than %d", got, test.service.MaxLength)
+			}
+		})
+	}
+}
+
+func TestNameHashSuffix(t *testing.T) {
+	suffix := regexp.MustCompile(`-[0-9a-f]{8}$`)
+	namer := New("dev", "Template")
+
+	first := namer.Name(XRaySamplingRule, "orders-service", "sampling")
+	if len(first) != XRaySamplingRule.MaxLength || !suffix.MatchString(first) {
+		t.Errorf("Name() = %q, want %d characters ending with an 8 character hash", first, XRaySamplingRule.MaxLength)
+	}
+	if again := namer.Name(XRaySamplingRule, "orders-service", "sampling"); again != first {
+		t.Errorf("Name() = %q, then %q for the same name", first, again)
+	}
+	// Names that only differ after the cut keep different names
+	if other := namer.Name(XRaySamplingRule, "orders-service", "sampling-rule"); other == first {
+		t.Errorf("Name() = %q for two different names", other)
+	}
+	// Names that fit are never hashed
+	if name := namer.Name(XRaySamplingRule, "orders"); suffix.MatchString(name) {
+		t.Errorf("Name() = %q, want no hash", name)
+	}
+}
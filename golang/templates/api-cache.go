@@ -0,0 +1,207 @@
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigateway"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/customresources"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Cache cluster sizes (in GB) supported by API Gateway.
+var apiCacheClusterSizes = []string{"0.5", "1.6", "6.1", "13.5", "28.4", "58.2", "118", "237"}
+
+// maxCacheTTLSeconds is the longest TTL API Gateway accepts for a method.
+const maxCacheTTLSeconds = 3600
+
+// APICacheSettings sizes the stage cache cluster. Caching is only switched on
+// for routes that declare an APIMethodCache.
+type APICacheSettings struct {
+	// Enabled defaults to IsProduction so dev stages never pay for a cache cluster.
+	Enabled *bool
+	// ClusterSize in GB, defaults to "0.5".
+	ClusterSize string
+	// RequireAuthorizationForFlush rejects "Cache-Control: max-age=0" from callers
+	// without execute-api:InvalidateCache. Defaults to true. Only SigV4 signed
+	// requests carry the identity of the caller, so the roles that flush the
+	// cache sign their requests and have APIResources.CacheInvalidationPolicy.
+	RequireAuthorizationForFlush *bool
+}
+
+// APIMethodCache configures caching for a single route.
+type APIMethodCache struct {
+	// TTLSeconds is between 0 and 3600, 0 disables caching for the method.
+	TTLSeconds      float64
+	QueryStringKeys []string
+	HeaderKeys      []string
+}
+
+func (settings APICacheSettings) enabled(props *PropsAPIResources) bool {
+	if settings.Enabled != nil {
+		return *settings.Enabled
+	}
+	return props.IsProduction
+}
+
+func (settings APICacheSettings) clusterSize() string {
+	if settings.ClusterSize == "" {
+		return "0.5"
+	}
+	return settings.ClusterSize
+}
+
+func (settings APICacheSettings) requireAuthorizationForFlush() bool {
+	if settings.RequireAuthorizationForFlush != nil {
+		return *settings.RequireAuthorizationForFlush
+	}
+	return true
+}
+
+// requestParameters returns the method request parameters used as cache keys.
+func (cache *APIMethodCache) requestParameters() []string {
+	var parameters []string
+	for _, key := range cache.QueryStringKeys {
+		parameters = append(parameters, "method.request.querystring."+key)
+	}
+	for _, key := range cache.HeaderKeys {
+		parameters = append(parameters, "method.request.header."+key)
+	}
+	return parameters
+}
+
+// addStageCacheOptions sizes the cache cluster on the deployment stage.
+func (self *APIResources) addStageCacheOptions(options *awsapigateway.StageOptions, props *PropsAPIResources) {
+	if !props.Cache.enabled(props) {
+		return
+	}
+
+	size := props.Cache.clusterSize()
+	if !slices.Contains(apiCacheClusterSizes, size) {
+		awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("Cache cluster size %q is not one of %s", size, strings.Join(apiCacheClusterSizes, ", ")))
+	}
+
+	options.CacheClusterEnabled = jsii.Bool(true)
+	options.CacheClusterSize = jsii.String(size)
+}
+
+// configureStageCache writes the stage method settings. Caching is disabled for
+// "/*/*" first, because API Gateway otherwise caches every GET method once the
+// cluster exists.
+func (self *APIResources) configureStageCache(api awsapigateway.RestApi, props *PropsAPIResources) {
+	if !props.Cache.enabled(props) {
+		return
+	}
+
+	requireAuthorization := props.Cache.requireAuthorizationForFlush()
+	methodSettings := []interface{}{
+		&awsapigateway.CfnStage_MethodSettingProperty{
+			ResourcePath:   jsii.String("/*"),
+			HttpMethod:     jsii.String("*"),
+			CachingEnabled: jsii.Bool(false),
+		},
+	}
+
+	var flushPatches []interface{}
+	for _, route := range props.Routes {
+		if route.Cache == nil {
+			continue
+		}
+		httpMethod := strings.ToUpper(route.Method)
+		if route.Cache.TTLSeconds < 0 || route.Cache.TTLSeconds > maxCacheTTLSeconds {
+			awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("Route %s %s: cache TTL %v is not between 0 and %d seconds", httpMethod, route.Path, route.Cache.TTLSeconds, maxCacheTTLSeconds))
+			continue
+		}
+		methodSettings = append(methodSettings, &awsapigateway.CfnStage_MethodSettingProperty{
+			ResourcePath:       jsii.String(methodSettingPath(route.Path)),
+			HttpMethod:         jsii.String(httpMethod),
+			CachingEnabled:     jsii.Bool(true),
+			CacheTtlInSeconds:  jsii.Number(route.Cache.TTLSeconds),
+			CacheDataEncrypted: jsii.Bool(true),
+		})
+		patchPath := stagePatchPath(route.Path, httpMethod)
+		flushPatches = append(flushPatches,
+			map[string]string{"op": "replace", "path": patchPath + "/caching/requireAuthorizationForCacheControl", "value": strconv.FormatBool(requireAuthorization)},
+			map[string]string{"op": "replace", "path": patchPath + "/caching/unauthorizedCacheControlHeaderStrategy", "value": "FAIL_WITH_403"},
+		)
+	}
+
+	stage := api.DeploymentStage().Node().DefaultChild().(awsapigateway.CfnStage)
+	stage.SetMethodSettings(&methodSettings)
+
+	// CloudFormation has no property for the cache flush authorization, so it is
+	// patched onto the stage once the method settings exist. An update of the
+	// method settings resets it, the physical id changes with them so that the
+	// patch runs again.
+	if len(flushPatches) > 0 {
+		stageName := api.DeploymentStage().StageName()
+		updateStage := &customresources.AwsSdkCall{
+			Service: jsii.String("APIGateway"),
+			Action:  jsii.String("updateStage"),
+			Parameters: map[string]interface{}{
+				"restApiId":       api.RestApiId(),
+				"stageName":       stageName,
+				"patchOperations": flushPatches,
+			},
+			PhysicalResourceId: customresources.PhysicalResourceId_Of(jsii.String(*api.RestApiId() + "-" + *stageName + "-cache-flush-" + settingsHash(methodSettings, flushPatches))),
+		}
+		flushAuthorization := customresources.NewAwsCustomResource(self.Stack, jsii.String("CacheFlushAuthorization"), &customresources.AwsCustomResourceProps{
+			OnCreate: updateStage,
+			OnUpdate: updateStage,
+			Policy: customresources.AwsCustomResourcePolicy_FromStatements(&[]awsiam.PolicyStatement{
+				awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+					Effect:    awsiam.Effect_ALLOW,
+					Actions:   jsii.Strings("apigateway:PATCH"),
+					Resources: jsii.Strings(fmt.Sprintf("arn:aws:apigateway:%s::/restapis/%s/stages/%s", *awscdk.Aws_REGION(), *api.RestApiId(), *stageName)),
+				}),
+			}),
+			InstallLatestAwsSdk: jsii.Bool(false),
+		})
+		flushAuthorization.Node().AddDependency(api.DeploymentStage())
+	}
+
+	// Callers attach this policy to the roles allowed to flush the cache, which
+	// send "Cache-Control: max-age=0" on SigV4 signed requests
+	self.CacheInvalidationPolicy = awsiam.NewManagedPolicy(self.Stack, jsii.String("CacheInvalidationPolicy"), &awsiam.ManagedPolicyProps{
+		Description: jsii.String(props.Environment + " permission to flush the " + props.ApiDomainName + " API cache"),
+		Statements: &[]awsiam.PolicyStatement{
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Effect:    awsiam.Effect_ALLOW,
+				Actions:   jsii.Strings("execute-api:InvalidateCache"),
+				Resources: &[]*string{api.ArnForExecuteApi(jsii.String("*"), jsii.String("/*"), api.DeploymentStage().StageName())},
+			}),
+		},
+	})
+}
+
+// settingsHash identifies the method settings and patches of a stage.
+func settingsHash(methodSettings []interface{}, patches []interface{}) string {
+	settings, _ := json.Marshal([]interface{}{methodSettings, patches})
+	sum := sha256.Sum256(settings)
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// methodSettingPath encodes a resource path the way CloudFormation expects it
+// in MethodSettings, e.g. "items/{id}" becomes "/~1items~1{id}". The root
+// resource is only the leading slash.
+func methodSettingPath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "/"
+	}
+	return "/~1" + strings.ReplaceAll(path, "/", "~1")
+}
+
+// stagePatchPath is the path of the settings of a method in the patch
+// operations of UpdateStage, e.g. "/~1items~1{id}/GET". The encoded root
+// resource is "~1".
+func stagePatchPath(path string, httpMethod string) string {
+	return "/" + strings.ReplaceAll("/"+strings.Trim(path, "/"), "/", "~1") + "/" + httpMethod
+}
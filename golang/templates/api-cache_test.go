@@ -0,0 +1,25 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestCacheTTLErrors(t *testing.T) {
+	app := awscdk.NewApp(nil)
+	props := apiTestProps()
+	props.Cache.Enabled = jsii.Bool(true)
+	props.Routes = []APIRoute{
+		{Path: "/items", Method: "GET", Cache: &APIMethodCache{TTLSeconds: 3600}},
+		{Path: "/items/{id}", Method: "GET", Cache: &APIMethodCache{TTLSeconds: 3601}},
+		{Path: "/orders", Method: "GET", Cache: &APIMethodCache{TTLSeconds: -1}},
+	}
+	stack := NewAPIResources(app, "api", props).Stack
+
+	assertAnnotationErrors(t, stack,
+		"Route GET /items/{id}: cache TTL 3601 is not between 0 and 3600 seconds",
+		"Route GET /orders: cache TTL -1 is not between 0 and 3600 seconds",
+	)
+}
@@ -0,0 +1,269 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigateway"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// APIIntegrationType selects what serves an APIRoute.
+type APIIntegrationType string
+
+const (
+	// LambdaIntegration proxies to the stack's Lambda function (the default).
+	LambdaIntegration APIIntegrationType = ""
+	// SQSSendMessageIntegration sends the request body to APIRoute.Queue.
+	SQSSendMessageIntegration APIIntegrationType = "sqs:SendMessage"
+	// DynamoDBPutItemIntegration stores the request body in APIRoute.Table.
+	DynamoDBPutItemIntegration APIIntegrationType = "dynamodb:PutItem"
+	// DynamoDBGetItemIntegration reads the item keyed by a path parameter from APIRoute.Table, see
+	// APIRoute.tableKeyParameter.
+	DynamoDBGetItemIntegration APIIntegrationType = "dynamodb:GetItem"
+	// StepFunctionsStartExecutionIntegration starts APIRoute.StateMachine with the request body as input.
+	StepFunctionsStartExecutionIntegration APIIntegrationType = "states:StartExecution"
+	// VPCLinkIntegration proxies to APIRoute.NetworkLoadBalancer or APIRoute.ApplicationLoadBalancer through a VPC link.
+	VPCLinkIntegration APIIntegrationType = "vpc-link"
+)
+
+// Default partition key attribute used by the DynamoDB integrations.
+const defaultTableKeyAttribute = "id"
+
+// Matches path parameters such as {id} and greedy ones such as {proxy+}
+var pathParameterPattern = regexp.MustCompile(`\{(\w+)\+?\}`)
+
+// known reports whether the type is one of the integration types above.
+func (integration APIIntegrationType) known() bool {
+	switch integration {
+	case LambdaIntegration, SQSSendMessageIntegration, DynamoDBPutItemIntegration, DynamoDBGetItemIntegration,
+		StepFunctionsStartExecutionIntegration, VPCLinkIntegration:
+		return true
+	}
+	return false
+}
+
+// escapeJSONString is the VTL expression of value escaped for a JSON string.
+// escapeJavaScript also escapes single quotes, which JSON doesn't allow.
+func escapeJSONString(value string) string {
+	return `$util.escapeJavaScript(` + value + `).replaceAll("\\'","'")`
+}
+
+// routeIntegration builds the integration for a route. integrationOptions already
+// carries the cache key mapping and is extended with the service specific
+// templates, responses and credentials role.
+func (self *APIResources) routeIntegration(route APIRoute, integrationOptions *awsapigateway.IntegrationOptions, methodOptions *awsapigateway.MethodOptions, lambdaFunction awslambda.IFunction) awsapigateway.Integration {
+	if route.Integration == LambdaIntegration {
+		return awsapigateway.NewLambdaIntegration(lambdaFunction, &awsapigateway.LambdaIntegrationOptions{
+			CacheKeyParameters: integrationOptions.CacheKeyParameters,
+			RequestParameters:  integrationOptions.RequestParameters,
+		})
+	}
+
+	if !route.Integration.known() {
+		awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("Route %s %s: unknown integration type %q", route.Method, route.Path, route.Integration))
+		return awsapigateway.NewMockIntegration(nil)
+	}
+	if !route.hasIntegrationTarget() {
+		awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("Route %s %s uses the %q integration without its target queue, table, state machine or load balancer", route.Method, route.Path, route.Integration))
+		return awsapigateway.NewMockIntegration(nil)
+	}
+	if _, ok := route.tableKeyParameter(); route.Integration == DynamoDBGetItemIntegration && !ok {
+		awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("Route %s %s: the %q integration needs a path parameter named %s, or a single path parameter, for the key of the item",
+			route.Method, route.Path, route.Integration, route.tableKeyAttribute()))
+		return awsapigateway.NewMockIntegration(nil)
+	}
+
+	if route.Integration == VPCLinkIntegration {
+		return self.vpcLinkIntegration(route, integrationOptions, methodOptions)
+	}
+
+	id := routeID(route)
+	credentialsRole := awsiam.NewRole(self.Stack, jsii.String(id+"IntegrationRole"), &awsiam.RoleProps{
+		AssumedBy:   awsiam.NewServicePrincipal(jsii.String("apigateway.amazonaws.com"), nil),
+		Description: jsii.String(fmt.Sprintf("API Gateway %s integration for %s %s", route.Integration, strings.ToUpper(route.Method), route.Path)),
+	})
+
+	integrationOptions.CredentialsRole = credentialsRole
+	integrationOptions.PassthroughBehavior = awsapigateway.PassthroughBehavior_NEVER
+	integrationOptions.IntegrationResponses = serviceIntegrationResponses(route)
+	methodOptions.MethodResponses = &[]*awsapigateway.MethodResponse{
+		{StatusCode: jsii.String("200")},
+		{StatusCode: jsii.String("400")},
+		{StatusCode: jsii.String("500")},
+	}
+	if route.Integration == DynamoDBGetItemIntegration {
+		// Missing items are answered with a 404 by the success template
+		methodResponses := append(*methodOptions.MethodResponses, &awsapigateway.MethodResponse{StatusCode: jsii.String("404")})
+		methodOptions.MethodResponses = &methodResponses
+	}
+
+	switch route.Integration {
+	case SQSSendMessageIntegration:
+		route.Queue.GrantSendMessages(credentialsRole)
+
+		requestParameters := map[string]*string{
+			"integration.request.header.Content-Type": jsii.String("'application/x-www-form-urlencoded'"),
+		}
+		if integrationOptions.RequestParameters != nil {
+			for k, v := range *integrationOptions.RequestParameters {
+				requestParameters[k] = v
+			}
+		}
+		integrationOptions.RequestParameters = &requestParameters
+		integrationOptions.RequestTemplates = &map[string]*string{
+			"application/json": jsii.String("Action=SendMessage&MessageBody=$util.urlEncode($input.body)"),
+		}
+
+		return awsapigateway.NewAwsIntegration(&awsapigateway.AwsIntegrationProps{
+			Service:               jsii.String("sqs"),
+			Path:                  jsii.String(*awscdk.Aws_ACCOUNT_ID() + "/" + *route.Queue.QueueName()),
+			IntegrationHttpMethod: jsii.String("POST"),
+			Options:               integrationOptions,
+		})
+
+	case DynamoDBPutItemIntegration, DynamoDBGetItemIntegration:
+		action := strings.TrimPrefix(string(route.Integration), "dynamodb:")
+		route.Table.Grant(credentialsRole, jsii.String(string(route.Integration)))
+		integrationOptions.RequestTemplates = &map[string]*string{
+			"application/json": jsii.String(dynamoDBRequestTemplate(route)),
+		}
+
+		return awsapigateway.NewAwsIntegration(&awsapigateway.AwsIntegrationProps{
+			Service:               jsii.String("dynamodb"),
+			Action:                jsii.String(action),
+			IntegrationHttpMethod: jsii.String("POST"),
+			Options:               integrationOptions,
+		})
+
+	default:
+		// StepFunctionsStartExecutionIntegration, the type is known
+		route.StateMachine.GrantStartExecution(credentialsRole)
+		integrationOptions.RequestTemplates = &map[string]*string{
+			"application/json": jsii.String(fmt.Sprintf(`{
+  "stateMachineArn": "%s",
+  "input": "%s"
+}`, *route.StateMachine.StateMachineArn(), escapeJSONString("$input.json('$')"))),
+		}
+
+		return awsapigateway.NewAwsIntegration(&awsapigateway.AwsIntegrationProps{
+			Service:               jsii.String("states"),
+			Action:                jsii.String("StartExecution"),
+			IntegrationHttpMethod: jsii.String("POST"),
+			Options:               integrationOptions,
+		})
+	}
+}
+
+// hasIntegrationTarget reports whether the route carries the resource its
+// integration type talks to.
+func (route APIRoute) hasIntegrationTarget() bool {
+	switch route.Integration {
+	case SQSSendMessageIntegration:
+		return route.Queue != nil
+	case DynamoDBPutItemIntegration, DynamoDBGetItemIntegration:
+		return route.Table != nil
+	case StepFunctionsStartExecutionIntegration:
+		return route.StateMachine != nil
+	case VPCLinkIntegration:
+		return route.NetworkLoadBalancer != nil || (route.ApplicationLoadBalancer != nil && route.ApplicationLoadBalancer.Vpc() != nil)
+	}
+	return false
+}
+
+func (route APIRoute) tableKeyAttribute() string {
+	if route.TableKeyAttribute == "" {
+		return defaultTableKeyAttribute
+	}
+	return route.TableKeyAttribute
+}
+
+// tableKeyParameter returns the path parameter holding the key of the item:
+// the one named like the key attribute, otherwise the only one of the path.
+func (route APIRoute) tableKeyParameter() (string, bool) {
+	matches := pathParameterPattern.FindAllStringSubmatch(route.Path, -1)
+	for _, match := range matches {
+		if match[1] == route.tableKeyAttribute() {
+			return match[1], true
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0][1], true
+	}
+	return "", false
+}
+
+func dynamoDBRequestTemplate(route APIRoute) string {
+	if route.Integration == DynamoDBGetItemIntegration {
+		keyParameter, _ := route.tableKeyParameter()
+		return fmt.Sprintf(`{
+  "TableName": "%s",
+  "Key": {
+    "%s": {"S": "%s"}
+  }
+}`, *route.Table.TableName(), route.tableKeyAttribute(), escapeJSONString("$input.params('"+keyParameter+"')"))
+	}
+
+	return fmt.Sprintf(`{
+  "TableName": "%s",
+  "Item": {
+    "%s": {"S": "$context.requestId"},
+    "body": {"S": "%s"},
+    "createdAt": {"N": "$context.requestTimeEpoch"}
+  }
+}`, *route.Table.TableName(), route.tableKeyAttribute(), escapeJSONString("$input.body"))
+}
+
+// serviceIntegrationResponses maps the service response onto 200/400/500 and
+// shapes the success body so callers never see the raw service payload.
+func serviceIntegrationResponses(route APIRoute) *[]*awsapigateway.IntegrationResponse {
+	var successTemplate string
+	switch route.Integration {
+	case SQSSendMessageIntegration:
+		successTemplate = `{"messageId": "$input.path('$.SendMessageResponse.SendMessageResult.MessageId')"}`
+	case DynamoDBPutItemIntegration:
+		successTemplate = `{"id": "$context.requestId"}`
+	case DynamoDBGetItemIntegration:
+		successTemplate = `#set($item = $input.path('$.Item'))
+#if("$item" == "")
+#set($context.responseOverride.status = 404)
+{"message": "Not Found"}
+#else
+$input.json('$.Item')
+#end`
+	case StepFunctionsStartExecutionIntegration:
+		successTemplate = `{"executionArn": "$input.path('$.executionArn')", "startDate": "$input.path('$.startDate')"}`
+	}
+
+	return &[]*awsapigateway.IntegrationResponse{
+		{
+			StatusCode: jsii.String("200"),
+			ResponseTemplates: &map[string]*string{
+				"application/json": jsii.String(successTemplate),
+			},
+		},
+		{
+			StatusCode:       jsii.String("400"),
+			SelectionPattern: jsii.String(`4\d{2}`),
+			ResponseTemplates: &map[string]*string{
+				"application/json": jsii.String(`{"message": "Bad Request"}`),
+			},
+		},
+		{
+			StatusCode:       jsii.String("500"),
+			SelectionPattern: jsii.String(`5\d{2}`),
+			ResponseTemplates: &map[string]*string{
+				"application/json": jsii.String(`{"message": "Internal Server Error"}`),
+			},
+		},
+	}
+}
+
+// routeID turns "GET items/{id}" into a construct id such as "GETitemsid".
+func routeID(route APIRoute) string {
+	return strings.ToUpper(route.Method) + strings.NewReplacer("/", "", "{", "", "}", "", "+", "").Replace(route.Path)
+}
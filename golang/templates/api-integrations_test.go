@@ -0,0 +1,55 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestRouteIntegrationErrors(t *testing.T) {
+	app := awscdk.NewApp(nil)
+	services := awscdk.NewStack(app, jsii.String("services"), &awscdk.StackProps{Env: testEnvironment})
+	table := awsdynamodb.Table_FromTableName(services, jsii.String("Orders"), jsii.String("orders"))
+
+	props := apiTestProps()
+	props.Routes = []APIRoute{
+		{Path: "/jobs", Method: "POST", Integration: "sqs:SendMesage"},
+		{Path: "/orders", Method: "POST", Integration: DynamoDBPutItemIntegration},
+		{Path: "/orders/{customerId}/{orderId}", Method: "GET", Integration: DynamoDBGetItemIntegration, Table: table},
+	}
+	stack := NewAPIResources(app, "api", props).Stack
+
+	assertAnnotationErrors(t, stack,
+		`Route POST /jobs: unknown integration type "sqs:SendMesage"`,
+		`Route POST /orders uses the "dynamodb:PutItem" integration without its target`,
+		`Route GET /orders/{customerId}/{orderId}: the "dynamodb:GetItem" integration needs a path parameter named id`,
+	)
+}
+
+func TestGetItemNotFoundResponse(t *testing.T) {
+	app := awscdk.NewApp(nil)
+	services := awscdk.NewStack(app, jsii.String("services"), &awscdk.StackProps{Env: testEnvironment})
+	table := awsdynamodb.Table_FromTableName(services, jsii.String("Orders"), jsii.String("orders"))
+
+	props := apiTestProps()
+	props.Routes = []APIRoute{
+		{Path: "/orders/{id}", Method: "GET", Integration: DynamoDBGetItemIntegration, Table: table},
+		{Path: "/orders", Method: "POST", Integration: DynamoDBPutItemIntegration, Table: table},
+	}
+	template := assertions.Template_FromStack(NewAPIResources(app, "api", props).Stack, nil)
+
+	notFound := assertions.Match_ArrayWith(&[]interface{}{
+		assertions.Match_ObjectLike(&map[string]interface{}{"StatusCode": "404"}),
+	})
+	template.HasResourceProperties(jsii.String("AWS::ApiGateway::Method"), map[string]interface{}{
+		"HttpMethod":      "GET",
+		"MethodResponses": notFound,
+	})
+	template.ResourcePropertiesCountIs(jsii.String("AWS::ApiGateway::Method"), map[string]interface{}{
+		"HttpMethod":      "POST",
+		"MethodResponses": notFound,
+	}, jsii.Number(0))
+}
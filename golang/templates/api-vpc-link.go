@@ -0,0 +1,128 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigateway"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awselasticloadbalancingv2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awselasticloadbalancingv2targets"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// vpcLinkIntegration proxies a route to an internal load balancer. REST API VPC
+// links only accept network load balancers, so an ApplicationLoadBalancer is
+// fronted by an internal NLB that forwards TCP to it.
+func (self *APIResources) vpcLinkIntegration(route APIRoute, integrationOptions *awsapigateway.IntegrationOptions, methodOptions *awsapigateway.MethodOptions) awsapigateway.Integration {
+	port := route.TargetPort
+	if port == 0 {
+		port = 80
+	}
+
+	loadBalancer := route.NetworkLoadBalancer
+	if loadBalancer == nil {
+		loadBalancer = self.applicationLoadBalancerFrontend(route, port)
+		if loadBalancer == nil {
+			return awsapigateway.NewMockIntegration(nil)
+		}
+	}
+
+	// Path parameters have to be passed on explicitly to an HTTP proxy
+	methodParameters := map[string]*bool{}
+	integrationParameters := map[string]*string{}
+	if methodOptions.RequestParameters != nil {
+		methodParameters = *methodOptions.RequestParameters
+	}
+	if integrationOptions.RequestParameters != nil {
+		integrationParameters = *integrationOptions.RequestParameters
+	}
+	for _, match := range pathParameterPattern.FindAllStringSubmatch(route.Path, -1) {
+		methodParameters["method.request.path."+match[1]] = jsii.Bool(true)
+		integrationParameters["integration.request.path."+match[1]] = jsii.String("method.request.path." + match[1])
+	}
+	if len(methodParameters) > 0 {
+		methodOptions.RequestParameters = &methodParameters
+		integrationOptions.RequestParameters = &integrationParameters
+	}
+
+	targetPath := route.TargetPath
+	if targetPath == "" {
+		targetPath = route.Path
+	}
+	targetPath = pathParameterPattern.ReplaceAllString(strings.Trim(targetPath, "/"), "{$1}")
+
+	integrationOptions.ConnectionType = awsapigateway.ConnectionType_VPC_LINK
+	integrationOptions.VpcLink = self.vpcLink(loadBalancer)
+
+	return awsapigateway.NewIntegration(&awsapigateway.IntegrationProps{
+		Type:                  awsapigateway.IntegrationType_HTTP_PROXY,
+		IntegrationHttpMethod: jsii.String(strings.ToUpper(route.Method)),
+		Uri:                   jsii.String(fmt.Sprintf("http://%s:%d/%s", *loadBalancer.LoadBalancerDnsName(), int(port), targetPath)),
+		Options:               integrationOptions,
+	})
+}
+
+// vpcLink returns the VPC link for a network load balancer, creating one the
+// first time the load balancer is used.
+func (self *APIResources) vpcLink(loadBalancer awselasticloadbalancingv2.INetworkLoadBalancer) awsapigateway.VpcLink {
+	if self.vpcLinks == nil {
+		self.vpcLinks = map[string]awsapigateway.VpcLink{}
+	}
+
+	key := *loadBalancer.Node().Path()
+	if link, ok := self.vpcLinks[key]; ok {
+		return link
+	}
+
+	link := awsapigateway.NewVpcLink(self.Stack, jsii.Sprintf("VpcLink%d", len(self.vpcLinks)+1), &awsapigateway.VpcLinkProps{
+		Description: jsii.String("VPC link to " + key),
+		Targets:     &[]awselasticloadbalancingv2.INetworkLoadBalancer{loadBalancer},
+	})
+	self.vpcLinks[key] = link
+
+	return link
+}
+
+// applicationLoadBalancerFrontend creates an internal NLB in the route's
+// FrontendSubnets that forwards the target port to the ALB listener. It
+// returns nil when the subnets don't exist.
+func (self *APIResources) applicationLoadBalancerFrontend(route APIRoute, port float64) awselasticloadbalancingv2.INetworkLoadBalancer {
+	if self.albFrontends == nil {
+		self.albFrontends = map[string]awselasticloadbalancingv2.INetworkLoadBalancer{}
+	}
+
+	alb := route.ApplicationLoadBalancer
+	key := fmt.Sprintf("%s:%d", *alb.Node().Path(), int(port))
+	if frontend, ok := self.albFrontends[key]; ok {
+		return frontend
+	}
+
+	subnets := route.FrontendSubnets
+	if subnets == nil {
+		subnets = &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS}
+		if len(*alb.Vpc().PrivateSubnets()) == 0 {
+			subnets = &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED}
+		}
+	}
+	if !validSubnetSelection(self.Stack, alb.Vpc(), subnets, fmt.Sprintf("VPC link frontend of route %s %s", route.Method, route.Path)) {
+		return nil
+	}
+
+	frontend := awselasticloadbalancingv2.NewNetworkLoadBalancer(self.Stack, jsii.Sprintf("VpcLinkFrontend%d", len(self.albFrontends)+1), &awselasticloadbalancingv2.NetworkLoadBalancerProps{
+		Vpc:            alb.Vpc(),
+		InternetFacing: jsii.Bool(false),
+		VpcSubnets:     subnets,
+	})
+
+	listener := frontend.AddListener(jsii.String("Listener"), &awselasticloadbalancingv2.BaseNetworkListenerProps{
+		Port: jsii.Number(port),
+	})
+	listener.AddTargets(jsii.String("Alb"), &awselasticloadbalancingv2.AddNetworkTargetsProps{
+		Port:    jsii.Number(port),
+		Targets: &[]awselasticloadbalancingv2.INetworkLoadBalancerTarget{awselasticloadbalancingv2targets.NewAlbTarget(alb, jsii.Number(port))},
+	})
+	self.albFrontends[key] = frontend
+
+	return frontend
+}
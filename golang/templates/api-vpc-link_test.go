@@ -0,0 +1,60 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awselasticloadbalancingv2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// isolatedLoadBalancerRoute routes to an internal ALB in a VPC without
+// private subnets.
+func isolatedLoadBalancerRoute(app awscdk.App) APIRoute {
+	network := awscdk.NewStack(app, jsii.String("network"), &awscdk.StackProps{Env: testEnvironment})
+	vpc := awsec2.NewVpc(network, jsii.String("Vpc"), &awsec2.VpcProps{
+		MaxAzs:      jsii.Number(2),
+		NatGateways: jsii.Number(0),
+		SubnetConfiguration: &[]*awsec2.SubnetConfiguration{
+			{Name: jsii.String("Public"), SubnetType: awsec2.SubnetType_PUBLIC},
+			{Name: jsii.String("Isolated"), SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED},
+		},
+	})
+	alb := awselasticloadbalancingv2.NewApplicationLoadBalancer(network, jsii.String("Alb"), &awselasticloadbalancingv2.ApplicationLoadBalancerProps{
+		Vpc:        vpc,
+		VpcSubnets: &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED},
+	})
+	return APIRoute{Path: "/orders", Method: "GET", Integration: VPCLinkIntegration, ApplicationLoadBalancer: alb}
+}
+
+func TestVPCLinkFrontendSubnets(t *testing.T) {
+	t.Run("isolated subnets", func(t *testing.T) {
+		app := awscdk.NewApp(nil)
+		props := apiTestProps()
+		props.Routes = []APIRoute{isolatedLoadBalancerRoute(app)}
+		stack := NewAPIResources(app, "api", props).Stack
+
+		assertAnnotationErrors(t, stack)
+		assertions.Template_FromStack(stack, nil).HasResourceProperties(jsii.String("AWS::ElasticLoadBalancingV2::LoadBalancer"), map[string]interface{}{
+			"Type":   "network",
+			"Scheme": "internal",
+			"Subnets": []interface{}{
+				map[string]interface{}{"Fn::ImportValue": assertions.Match_StringLikeRegexp(jsii.String("VpcIsolatedSubnet1"))},
+				map[string]interface{}{"Fn::ImportValue": assertions.Match_StringLikeRegexp(jsii.String("VpcIsolatedSubnet2"))},
+			},
+		})
+	})
+
+	t.Run("missing subnets", func(t *testing.T) {
+		app := awscdk.NewApp(nil)
+		route := isolatedLoadBalancerRoute(app)
+		route.FrontendSubnets = &awsec2.SubnetSelection{SubnetGroupName: jsii.String("Private")}
+		props := apiTestProps()
+		props.Routes = []APIRoute{route}
+		stack := NewAPIResources(app, "api", props).Stack
+
+		assertAnnotationErrors(t, stack, "VPC link frontend of route GET /orders: There are no subnet groups with name 'Private' in this VPC")
+	})
+}
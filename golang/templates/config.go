@@ -0,0 +1,132 @@
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Config is the Go counterpart of config/Config.ts and is read from the same
+// config/config.json.
+type Config struct {
+	Author     string `json:"author"`
+	Project    string `json:"project"`
+	CostCenter string `json:"costCenter"`
+	Owner      string `json:"owner"`
+	// TagPolicy replaces DefaultTagPolicy when set.
+	TagPolicy *TagPolicy `json:"tagPolicy"`
+
+	// compiledTagPatterns caches tagPatterns
+	compiledTagPatterns map[string]*regexp.Regexp
+}
+
+// Environment variables that take precedence over the JSON files
+const (
+	configAuthorVariable  = "CONFIG_AUTHOR"
+	configProjectVariable = "CONFIG_PROJECT"
+)
+
+// LoadConfig reads the config file at path, e.g. config/config.json, then
+// overlays config.<environment>.json from the same directory when it exists
+// and finally the CONFIG_AUTHOR and CONFIG_PROJECT environment variables.
+// Other fields are ignored, the files also hold the settings of the apps and
+// of config/Config.ts.
+func LoadConfig(path string, environment string) (*Config, error) {
+	config := &Config{}
+	if err := readConfigFiles(path, environment, config, false); err != nil {
+		return nil, err
+	}
+	if err := config.Resolve(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Resolve applies the CONFIG_AUTHOR and CONFIG_PROJECT environment variables
+// to a config read with ReadConfigFiles and validates it.
+func (config *Config) Resolve() error {
+	if author, ok := os.LookupEnv(configAuthorVariable); ok {
+		config.Author = author
+	}
+	if project, ok := os.LookupEnv(configProjectVariable); ok {
+		config.Project = project
+	}
+	return config.validate()
+}
+
+// requireConfig reports a missing Config on the scope and returns an empty one
+// so that synth can finish and print the error.
+func requireConfig(scope constructs.IConstruct, config *Config) *Config {
+	if config == nil {
+		awscdk.Annotations_Of(scope).AddError(jsii.String("Config is required, load it with LoadConfig"))
+		return &Config{}
+	}
+	return config
+}
+
+// ReadConfigFiles decodes the config file at path and then its
+// config.<environment>.json overlay, when it exists, into target. Apps use it
+// to read their own settings from the config files. Fields target doesn't
+// have are errors, so apps embed Config in their settings and call
+// Config.Resolve instead of LoadConfig.
+func ReadConfigFiles(path string, environment string, target any) error {
+	return readConfigFiles(path, environment, target, true)
+}
+
+func readConfigFiles(path string, environment string, target any, strict bool) error {
+	if err := readConfigFile(path, target, strict); err != nil {
+		return err
+	}
+
+	if environment != "" {
+		overlay := strings.TrimSuffix(path, ".json") + "." + environment + ".json"
+		err := readConfigFile(overlay, target, strict)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readConfigFile(path string, target any, strict bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	defer file.Close()
+
+	// Fields missing from an overlay keep the value of the base file
+	decoder := json.NewDecoder(file)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	return nil
+}
+
+func (config *Config) validate() error {
+	var missing []string
+	if config.Author == "" {
+		missing = append(missing, "author")
+	}
+	if config.Project == "" {
+		missing = append(missing, "project")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("config: %s must be specified", strings.Join(missing, " and "))
+	}
+	if _, err := config.tagPatterns(); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeConfigFiles writes the files to a temporary directory and returns the
+// path of config.json.
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// unsetConfigVariables clears the overrides of the environment running the
+// tests, they are restored at the end of the test.
+func unsetConfigVariables(t *testing.T) {
+	for _, variable := range []string{configAuthorVariable, configProjectVariable} {
+		t.Setenv(variable, "")
+		os.Unsetenv(variable)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	files := map[string]string{
+		"config.json":     `{"author": "Author", "project": "Template", "owner": "platform"}`,
+		"config.dev.json": `{"project": "Template-dev", "costCenter": "cc-1"}`,
+	}
+
+	t.Run("overlay", func(t *testing.T) {
+		unsetConfigVariables(t)
+		config, err := LoadConfig(writeConfigFiles(t, files), "dev")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := [4]string{config.Author, config.Project, config.CostCenter, config.Owner}
+		want := [4]string{"Author", "Template-dev", "cc-1", "platform"}
+		if got != want {
+			t.Errorf("LoadConfig() author, project, cost center and owner = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("without overlay", func(t *testing.T) {
+		unsetConfigVariables(t)
+		config, err := LoadConfig(writeConfigFiles(t, files), "prod")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Project != "Template" || config.CostCenter != "" {
+			t.Errorf("LoadConfig() = %+v, want the base file only", *config)
+		}
+	})
+
+	t.Run("other fields", func(t *testing.T) {
+		unsetConfigVariables(t)
+		files := map[string]string{
+			"config.json":     `{"author": "Author", "project": "Template", "region": "us-east-1"}`,
+			"config.dev.json": `{"api": {"domainName": "example.com"}}`,
+		}
+		config, err := LoadConfig(writeConfigFiles(t, files), "dev")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Author != "Author" || config.Project != "Template" {
+			t.Errorf("LoadConfig() = %+v, want author Author and project Template", *config)
+		}
+	})
+
+	t.Run("environment variables", func(t *testing.T) {
+		t.Setenv(configAuthorVariable, "CI")
+		t.Setenv(configProjectVariable, "Override")
+		config, err := LoadConfig(writeConfigFiles(t, files), "dev")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Author != "CI" || config.Project != "Override" {
+			t.Errorf("LoadConfig() = %+v, want author CI and project Override", *config)
+		}
+	})
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"missing fields", map[string]string{"config.json": `{}`}, "config: author and project must be specified"},
+		{"missing project", map[string]string{"config.json": `{"author": "Author"}`}, "config: project must be specified"},
+		{"emptied by overlay", map[string]string{
+			"config.json":     `{"author": "Author", "project": "Template"}`,
+			"config.dev.json": `{"author": ""}`,
+		}, "config: author must be specified"},
+		{"invalid JSON", map[string]string{"config.json": `{"author": "Author",}`}, "parsing config"},
+		{"invalid pattern", map[string]string{
+			"config.json": `{"author": "Author", "project": "Template", "tagPolicy": {"allowedValues": {"owner": "("}}}`,
+		}, "config: tag policy pattern for owner"},
+		{"missing file", map[string]string{}, "reading config"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unsetConfigVariables(t)
+			_, err := LoadConfig(writeConfigFiles(t, test.files), "dev")
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("LoadConfig() error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+// The checked-in files hold the settings of the CLI and of config/Config.ts
+// next to the fields of Config.
+func TestLoadConfigCheckedIn(t *testing.T) {
+	unsetConfigVariables(t)
+	_, file, _, _ := runtime.Caller(0)
+	path := filepath.Join(filepath.Dir(file), "..", "..", "config", "config.json")
+
+	config, err := LoadConfig(path, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Author == "" || config.Project == "" {
+		t.Errorf("LoadConfig() = %+v, want the author and project of %s", *config, path)
+	}
+}
+
+func TestReadConfigFilesUnknownFields(t *testing.T) {
+	type settings struct {
+		Config
+		Region string `json:"region"`
+	}
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"known fields", map[string]string{"config.json": `{"author": "Author", "region": "us-east-1"}`}, ""},
+		{"unknown field", map[string]string{"config.json": `{"autor": "Author", "region": "us-east-1"}`}, `unknown field "autor"`},
+		{"unknown overlay field", map[string]string{
+			"config.json":     `{"author": "Author"}`,
+			"config.dev.json": `{"tagPolicy": {"requried": ["owner"]}}`,
+		}, `unknown field "requried"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ReadConfigFiles(writeConfigFiles(t, test.files), "dev", &settings{})
+			switch {
+			case test.want == "" && err != nil:
+				t.Errorf("ReadConfigFiles() error = %v", err)
+			case test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)):
+				t.Errorf("ReadConfigFiles() error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
@@ -2,18 +2,26 @@ package templates
 
 import (
 	"path/filepath"
+	"strings"
 
-	"github.com/aws/aws-cdk-go/awscdk/awsWafv2"
 	"github.com/aws/aws-cdk-go/awscdk/v2"
-	"github.com/aws/aws-cdk-go/awscdk/v2/awsacm"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigateway"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awselasticloadbalancingv2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53targets"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssqs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsstepfunctions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awswafv2"
 	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
+
+	"cdk-template/naming"
 )
 
 type PropsAPIResources struct {
@@ -25,10 +33,76 @@ type PropsAPIResources struct {
 	HostedZoneId     string
 	ApiDomainName    string
 	IsProduction     bool
+	// Config holds the project and author, see LoadConfig.
+	Config *Config
+	Routes []APIRoute
+	Cache  APICacheSettings
+	// APIType switches between a REST API (default) and an HTTP API.
+	APIType APIType
+	// JWTAuthorizer protects the routes of an HTTP API, which has no API key
+	// or WAF. HTTP APIs without it are an error unless AllowUnauthenticated.
+	JWTAuthorizer        *JWTAuthorizerSettings
+	AllowUnauthenticated bool
+	// Alarms enables the alarms and dashboard of the API stack when set.
+	Alarms *APIAlarmSettings
+	// Tracing enables X-Ray on the API stage and the Lambda function when set.
+	Tracing *TracingSettings
+	// LambdaArchitecture defaults to x86_64.
+	LambdaArchitecture awslambda.Architecture
+	// Secrets exposes existing secrets and parameters to the Lambda function.
+	Secrets *LambdaSecretsSettings
+	// VPC attaches the Lambda function to an existing VPC when set.
+	VPC *LambdaVPCSettings
+}
+
+// APIAlarmSettings configures where the API stack alarms are sent.
+type APIAlarmSettings struct {
+	Emails   []string
+	Webhooks []string
+	// Thresholds overrides the default thresholds, keyed by environment.
+	Thresholds map[string]AlarmThresholds
+}
+
+// APIRoute adds a method to the API. Routes are served by the stack's Lambda
+// function unless Integration selects a direct service integration, in which
+// case the matching Queue, Table, StateMachine or load balancer must be set.
+type APIRoute struct {
+	Path        string
+	Method      string
+	Cache       *APIMethodCache
+	Integration APIIntegrationType
+
+	Queue        awssqs.IQueue
+	Table        awsdynamodb.ITable
+	StateMachine awsstepfunctions.IStateMachine
+	// TableKeyAttribute is the partition key of Table, defaults to "id".
+	TableKeyAttribute string
+
+	NetworkLoadBalancer     awselasticloadbalancingv2.INetworkLoadBalancer
+	ApplicationLoadBalancer awselasticloadbalancingv2.IApplicationLoadBalancer
+	// TargetPort of the load balancer listener, defaults to 80.
+	TargetPort float64
+	// TargetPath on the load balancer, defaults to Path.
+	TargetPath string
+	// FrontendSubnets of the internal NLB in front of ApplicationLoadBalancer,
+	// default to the private subnets of its VPC, or the isolated ones for VPCs
+	// without. The first route of a load balancer and port sets them.
+	FrontendSubnets *awsec2.SubnetSelection
 }
 
 type APIResources struct {
 	awscdk.Stack
+	CacheInvalidationPolicy awsiam.IManagedPolicy
+	Observability           *Observability
+	// LambdaSecurityGroup is set when the Lambda function is attached to a VPC,
+	// allow it on the databases and caches the function connects to.
+	LambdaSecurityGroup awsec2.ISecurityGroup
+
+	deadLetterTopic awssns.ITopic
+	vpcLinks        map[string]awsapigateway.VpcLink
+	albFrontends    map[string]awselasticloadbalancingv2.INetworkLoadBalancer
+	config          *Config
+	names           naming.Namer
 }
 
 type APIObject struct {
@@ -36,31 +110,30 @@ type APIObject struct {
 	ApiGatewayDomainName awsapigateway.IDomainName
 }
 
-type Config struct {
-	project string
-	author  string
-}
-
-// Initialize Config
-var config = Config{
-	project: "YourProjectName",
-	author:  "YourName",
-}
-
 func NewAPIResources(scope constructs.Construct, id string, props *PropsAPIResources) *APIResources {
+	if props.APIType == HTTPAPI {
+		return NewHTTPAPIResources(scope, id, props)
+	}
+
 	self := &APIResources{
-		awscdk.NewStack(scope, &id, &props.StackProps),
+		Stack: awscdk.NewStack(scope, &id, &props.StackProps),
+	}
+	self.config = requireConfig(self.Stack, props.Config)
+	self.names = naming.New(props.Environment, self.config.Project)
+
+	if props.JWTAuthorizer != nil || props.AllowUnauthenticated {
+		awscdk.Annotations_Of(self.Stack).AddWarning(jsii.String("JWTAuthorizer and AllowUnauthenticated are only used by HTTP APIs, the REST API keeps its API key"))
 	}
 
 	domainName := props.DomainName
 	golangCodeAsset := "sample-code/golang-sample.zip"
 
-	lambdaFunction, lambdaRole := self.createLambdaFunctionAndRole(domainName, props, golangCodeAsset)
+	lambdaFunction, _ := self.createLambdaFunctionAndRole(domainName, props, golangCodeAsset)
 
 	apiObject := self.addAPIResources(props, lambdaFunction)
 
 	// Create a Lambda permission for API Gateway to invoke the Lambda function
-	awslambda.NewCfnPermission(self, jsii.String(domainName+"Permission"), &awslambda.CfnPermissionProps{
+	awslambda.NewCfnPermission(self.Stack, jsii.String(domainName+"Permission"), &awslambda.CfnPermissionProps{
 		Action:       jsii.String("lambda:InvokeFunction"),
 		Principal:    jsii.String("apigateway.amazonaws.com"),
 		SourceArn:    apiObject.api.RestApiId(),
@@ -69,16 +142,16 @@ func NewAPIResources(scope constructs.Construct, id string, props *PropsAPIResou
 
 	self.createWAF(domainName, apiObject.api)
 	self.createRecordSetsInRoute53(props, domainName, apiObject)
+	self.createObservability(props, apiObject.api.(awsapigateway.RestApi), lambdaFunction, "MyWebACLMetrics")
+	self.createSamplingRule(props)
 
-	self.addTags(lambdaFunction.LatestVersion().Stack(), props)
-	self.addTags(apiObject.api.Stack(), props)
-	self.addTags(lambdaRole.Stack(), props)
+	ApplyStandardTags(self.Stack, self.config, props.Environment, props.ApiDomainName)
 
 	return self
 }
 
 func (self *APIResources) createRecordSetsInRoute53(props *PropsAPIResources, domainName string, apiObject *APIObject) {
-	hostedZone := awsroute53.HostedZone_FromHostedZoneAttributes(self, jsii.String("HZA"+*apiObject.api.RestApiName()), &awsroute53.HostedZoneAttributes{
+	hostedZone := awsroute53.HostedZone_FromHostedZoneAttributes(self.Stack, jsii.String("HZA"+*apiObject.api.RestApiName()), &awsroute53.HostedZoneAttributes{
 		HostedZoneId: &props.HostedZoneId,
 		ZoneName:     &props.ApiDomainName,
 	})
@@ -87,7 +160,7 @@ func (self *APIResources) createRecordSetsInRoute53(props *PropsAPIResources, do
 	apiGatewayDomainTarget := awsroute53targets.NewApiGatewayDomain(apiObject.ApiGatewayDomainName)
 
 	// Create a A record in Route 53 for the custom domain name
-	awsroute53.NewARecord(self, jsii.String("ARecord"+*apiObject.api.RestApiName()), &awsroute53.ARecordProps{
+	awsroute53.NewARecord(self.Stack, jsii.String("ARecord"+*apiObject.api.RestApiName()), &awsroute53.ARecordProps{
 		Zone:           hostedZone,
 		RecordName:     &props.ApiDomainName,
 		Target:         awsroute53.RecordTarget_FromAlias(apiGatewayDomainTarget),
@@ -100,17 +173,19 @@ func (self *APIResources) createRecordSetsInRoute53(props *PropsAPIResources, do
 func (self *APIResources) createLambdaFunctionAndRole(domainName string, props *PropsAPIResources, golangCodeAsset string) (awslambda.IFunction, awsiam.IRole) {
 	bucketName := domainName + "-archive"
 
-	deadLetterTopic := awssns.NewTopic(self, jsii.String("topic"+domainName), &awssns.TopicProps{
-		DisplayName: jsii.String(props.Environment + config.project + "DeadLetterTopic"),
-		TopicName:   jsii.String(props.Environment + "-" + config.project + "-dead-letter-topic"),
+	deadLetterTopic := awssns.NewTopic(self.Stack, jsii.String("topic"+domainName), &awssns.TopicProps{
+		DisplayName: jsii.String(props.Environment + self.config.Project + "DeadLetterTopic"),
+		TopicName:   jsii.String(self.names.Name(naming.SNSTopic, "dead-letter-topic")),
 	})
 
+	self.deadLetterTopic = deadLetterTopic
 	lambdaRole := self.createLambdaRole(deadLetterTopic, props)
 
 	seconds := float64(10)
 	dir := filepath.Dir(golangCodeAsset)
-	lambdaFunction := awslambda.NewFunction(self, jsii.String("lambda"+domainName), &awslambda.FunctionProps{
+	functionProps := &awslambda.FunctionProps{
 		Runtime:         awslambda.Runtime_PROVIDED_AL2(),
+		Architecture:    props.LambdaArchitecture,
 		Handler:         jsii.String("bootstrap"),
 		Code:            awslambda.Code_FromAsset(&dir, nil),
 		MemorySize:      jsii.Number(512),
@@ -119,11 +194,16 @@ func (self *APIResources) createLambdaFunctionAndRole(domainName string, props *
 		Role:            lambdaRole,
 		RetryAttempts:   jsii.Number(0),
 		Description:     jsii.String(props.Environment + " Lambda Function to Save the Resources"),
-		FunctionName:    jsii.String(props.Environment + "-lambda-save-resources"),
+		FunctionName:    jsii.String(self.names.Name(naming.LambdaFunction, "save-resources")),
 		DeadLetterTopic: deadLetterTopic,
-	})
+	}
+	applyTracing(functionProps, props.Tracing)
+	self.applySecrets(functionProps, props.Secrets, lambdaRole)
+	self.applyVPC(functionProps, props, lambdaRole)
+	lambdaFunction := awslambda.NewFunction(self.Stack, jsii.String("lambda"+domainName), functionProps)
+	self.addLambdaEndpoints(props, lambdaFunction, bucketName)
 
-	logGroupArn := jsii.Sprintf("arn:aws:logs:%s:%s:log-group:/aws/lambda/%s:*", *awscdk.Aws_REGION(), *awscdk.Aws_ACCOUNT_ID(), lambdaFunction.FunctionName())
+	logGroupArn := jsii.Sprintf("arn:aws:logs:%s:%s:log-group:/aws/lambda/%s:*", *awscdk.Aws_REGION(), *awscdk.Aws_ACCOUNT_ID(), *lambdaFunction.FunctionName())
 
 	createLogGroupStatement := awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
 		Effect:    awsiam.Effect_ALLOW,
@@ -132,7 +212,7 @@ func (self *APIResources) createLambdaFunctionAndRole(domainName string, props *
 	})
 
 	// provide permissions to describe the user pool scoped to the ARN the user pool
-	lambdaFunction.Role().AttachInlinePolicy(awsiam.NewPolicy(self, jsii.String("userpool-policy"), &awsiam.PolicyProps{
+	lambdaFunction.Role().AttachInlinePolicy(awsiam.NewPolicy(self.Stack, jsii.String("userpool-policy"), &awsiam.PolicyProps{
 		Statements: &[]awsiam.PolicyStatement{
 			createLogGroupStatement,
 		},
@@ -141,10 +221,31 @@ func (self *APIResources) createLambdaFunctionAndRole(domainName string, props *
 	return lambdaFunction, lambdaRole
 }
 
+func (self *APIResources) createObservability(props *PropsAPIResources, api APIMetrics, lambdaFunction awslambda.IFunction, webACLMetricName string) {
+	if props.Alarms == nil {
+		return
+	}
+
+	self.Observability = NewObservability(self.Stack, "Observability", &ObservabilityProps{
+		Environment:      props.Environment,
+		Project:          self.config.Project,
+		IsProduction:     props.IsProduction,
+		Name:             props.ApiDomainName,
+		AlarmEmails:      props.Alarms.Emails,
+		AlarmWebhooks:    props.Alarms.Webhooks,
+		Thresholds:       props.Alarms.Thresholds,
+		Api:              api,
+		Functions:        []awslambda.IFunction{lambdaFunction},
+		WebACLMetricName: webACLMetricName,
+		DeadLetterTopic:  self.deadLetterTopic,
+	})
+}
+
 func (self *APIResources) addAPIResources(props *PropsAPIResources, lambdaFunction awslambda.IFunction) *APIObject {
-	api := awsapigateway.NewRestApi(self, &props.ApiDomainName, &awsapigateway.RestApiProps{
-		RestApiName: jsii.String(props.ApiDomainName),
-		Description: jsii.String(props.ApiDomainName + " API Gateway for the " + props.Environment + " environment"),
+	api := awsapigateway.NewRestApi(self.Stack, &props.ApiDomainName, &awsapigateway.RestApiProps{
+		RestApiName:   jsii.String(props.ApiDomainName),
+		Description:   jsii.String(props.ApiDomainName + " API Gateway for the " + props.Environment + " environment"),
+		DeployOptions: self.stageOptions(props),
 	})
 
 	saveResource := api.Root().AddResource(jsii.String("save"), nil)
@@ -166,6 +267,9 @@ func (self *APIResources) addAPIResources(props *PropsAPIResources, lambdaFuncti
 		},
 	})
 
+	self.addRoutes(api, props, lambdaFunction)
+	self.configureStageCache(api, props)
+
 	usagePlan := api.AddUsagePlan(jsii.String("MyUsagePlan"), &awsapigateway.UsagePlanProps{
 		Name:        jsii.String(props.DomainName + "UsagePlan"),
 		Description: jsii.String(props.DomainName + " Usage plan for My API"),
@@ -191,9 +295,9 @@ func (self *APIResources) addAPIResources(props *PropsAPIResources, lambdaFuncti
 		Stage: api.DeploymentStage(),
 	})
 
-	certificate := awsacm.Certificate_FromCertificateArn(self, jsii.Sprintf("%sCertificate", props.ApiDomainName), &props.CertificateArn)
+	certificate := awscertificatemanager.Certificate_FromCertificateArn(self.Stack, jsii.Sprintf("%sCertificate", props.ApiDomainName), &props.CertificateArn)
 
-	apiGatewayDomainName := awsapigateway.NewDomainName(self, jsii.Sprintf("%sApiGatewayDomainName", props.ApiDomainName), &awsapigateway.DomainNameProps{
+	apiGatewayDomainName := awsapigateway.NewDomainName(self.Stack, jsii.Sprintf("%sApiGatewayDomainName", props.ApiDomainName), &awsapigateway.DomainNameProps{
 		DomainName:   &props.ApiDomainName,
 		Certificate:  certificate,
 		EndpointType: awsapigateway.EndpointType_EDGE,
@@ -204,6 +308,42 @@ func (self *APIResources) addAPIResources(props *PropsAPIResources, lambdaFuncti
 	return &APIObject{api: api, ApiGatewayDomainName: apiGatewayDomainName}
 }
 
+func (self *APIResources) stageOptions(props *PropsAPIResources) *awsapigateway.StageOptions {
+	options := &awsapigateway.StageOptions{}
+	self.addStageCacheOptions(options, props)
+	if props.Tracing != nil {
+		options.TracingEnabled = jsii.Bool(true)
+	}
+	return options
+}
+
+func (self *APIResources) addRoutes(api awsapigateway.RestApi, props *PropsAPIResources, lambdaFunction awslambda.IFunction) {
+	for _, route := range props.Routes {
+		integrationOptions := &awsapigateway.IntegrationOptions{}
+		methodOptions := &awsapigateway.MethodOptions{
+			ApiKeyRequired: jsii.Bool(true),
+		}
+
+		// Cache keys have to be declared on the method and mapped through the integration
+		if route.Cache != nil {
+			cacheKeys := route.Cache.requestParameters()
+			methodParameters := map[string]*bool{}
+			integrationParameters := map[string]*string{}
+			for _, parameter := range cacheKeys {
+				methodParameters[parameter] = jsii.Bool(false)
+				integrationParameters[strings.Replace(parameter, "method.", "integration.", 1)] = jsii.String(parameter)
+			}
+			methodOptions.RequestParameters = &methodParameters
+			integrationOptions.RequestParameters = &integrationParameters
+			integrationOptions.CacheKeyParameters = jsii.Strings(cacheKeys...)
+		}
+
+		integration := self.routeIntegration(route, integrationOptions, methodOptions, lambdaFunction)
+		resource := api.Root().ResourceForPath(jsii.String(strings.Trim(route.Path, "/")))
+		resource.AddMethod(jsii.String(strings.ToUpper(route.Method)), integration, methodOptions)
+	}
+}
+
 func (self *APIResources) mockOptionsIntegration(props *PropsAPIResources) awsapigateway.MockIntegration {
 	return awsapigateway.NewMockIntegration(&awsapigateway.IntegrationOptions{
 		IntegrationResponses: &[]*awsapigateway.IntegrationResponse{
@@ -225,11 +365,10 @@ func (self *APIResources) mockOptionsIntegration(props *PropsAPIResources) awsap
 
 func (self *APIResources) createLambdaRole(deadLetterTopic awssns.ITopic, props *PropsAPIResources) awsiam.IRole {
 	lambdaFunctionRole := jsii.Sprintf("%s%sLambda Function Role", props.Environment, props.DomainName)
-	lambdaFunctionRoleName := jsii.Sprintf("%s%sLambdaFunctionRole", props.Environment, config.project)
-	lambdaRole := awsiam.NewRole(self, jsii.Sprintf("%sRole", props.DomainName), &awsiam.RoleProps{
+	lambdaRole := awsiam.NewRole(self.Stack, jsii.Sprintf("%sRole", props.DomainName), &awsiam.RoleProps{
 		AssumedBy:   awsiam.NewServicePrincipal(jsii.String("lambda.amazonaws.com"), nil),
 		Description: jsii.String(*lambdaFunctionRole),
-		RoleName:    jsii.String(*lambdaFunctionRoleName),
+		RoleName:    jsii.String(self.names.Name(naming.IAMRole, "lambda-function-role")),
 	})
 
 	deadLetterTopic.GrantPublish(lambdaRole)
@@ -237,63 +376,56 @@ func (self *APIResources) createLambdaRole(deadLetterTopic awssns.ITopic, props
 	return lambdaRole
 }
 
-func (self *APIResources) addTags(resource awscdk.ITaggable, props *PropsAPIResources) {
-	resource.Tags().SetTag(jsii.String("environment"), jsii.String(props.Environment), jsii.Number(1), jsii.Bool(true))
-	resource.Tags().SetTag(jsii.String("project"), jsii.String(config.project), jsii.Number(2), jsii.Bool(true))
-	resource.Tags().SetTag(jsii.String("author"), jsii.String(config.author), jsii.Number(3), jsii.Bool(true))
-	resource.Tags().SetTag(jsii.String("site"), jsii.String(props.ApiDomainName), jsii.Number(4), jsii.Bool(true))
-}
-
 func (self *APIResources) createWAF(domainName string, api awsapigateway.IRestApi) {
 	// Create IP sets for allowed IPs
-	ipSet1 := awsWafv2.NewCfnIPSet(self, jsii.String("IPSet1"), &awsWafv2.CfnIPSetProps{
-		Addresses:        []string{"192.0.2.0/24", "198.51.100.0/24"},
+	ipSet1 := awswafv2.NewCfnIPSet(self.Stack, jsii.String("IPSet1"), &awswafv2.CfnIPSetProps{
+		Addresses:        jsii.Strings("192.0.2.0/24", "198.51.100.0/24"),
 		IpAddressVersion: jsii.String("IPV4"),
 		Scope:            jsii.String("REGIONAL"),
-		Name:             jsii.String("office IP"),
+		Name:             jsii.String(self.names.Name(naming.WAF, "office-ip")),
 	})
 
-	ipSet2 := awsWafv2.NewCfnIPSet(self, jsii.String("IPSet2"), &awsWafv2.CfnIPSetProps{
-		Addresses:        []string{"203.0.113.0/24", "2001:0db8:85a3:0000:0000:8a2e:0370:7334"},
+	ipSet2 := awswafv2.NewCfnIPSet(self.Stack, jsii.String("IPSet2"), &awswafv2.CfnIPSetProps{
+		Addresses:        jsii.Strings("203.0.113.0/24", "2001:0db8:85a3:0000:0000:8a2e:0370:7334"),
 		IpAddressVersion: jsii.String("IPV6"),
 		Scope:            jsii.String("REGIONAL"),
-		Name:             jsii.String("remote Consultant Home IP"),
+		Name:             jsii.String(self.names.Name(naming.WAF, "remote-consultant-home-ip")),
 	})
 
 	// Create a rule group containing the IP sets
-	ruleGroup := awsWafv2.NewCfnRuleGroup(self, jsii.String("IPRuleGroup"), &awsWafv2.CfnRuleGroupProps{
+	ruleGroup := awswafv2.NewCfnRuleGroup(self.Stack, jsii.String("IPRuleGroup"), &awswafv2.CfnRuleGroupProps{
 		Capacity: jsii.Number(100),
 		Scope:    jsii.String("REGIONAL"),
-		Name:     jsii.String("Allow these IPs  from office and remote"),
-		VisibilityConfig: &awsWafv2.CfnRuleGroup_VisibilityConfigProperty{
+		Name:     jsii.String(self.names.Name(naming.WAF, "allow-office-and-remote-ips")),
+		VisibilityConfig: &awswafv2.CfnRuleGroup_VisibilityConfigProperty{
 			CloudWatchMetricsEnabled: jsii.Bool(true),
 			MetricName:               jsii.String("IPRuleGroupMetrics"),
 			SampledRequestsEnabled:   jsii.Bool(true),
 		},
-		Rules: []awsWafv2.CfnRuleGroup_RuleProperty{
-			{
+		Rules: &[]interface{}{
+			&awswafv2.CfnRuleGroup_RuleProperty{
 				Name:     jsii.String("AllowFromIPSet1"),
 				Priority: jsii.Number(1),
-				Statement: &awsWafv2.CfnRuleGroup_StatementOneProperty{
-					IpSetReferenceStatement: &awsWafv2.CfnRuleGroup_IpSetReferenceStatementProperty{
+				Statement: &awswafv2.CfnRuleGroup_StatementProperty{
+					IpSetReferenceStatement: &awswafv2.CfnRuleGroup_IPSetReferenceStatementProperty{
 						Arn: ipSet1.AttrArn(),
 					},
 				},
-				VisibilityConfig: &awsWafv2.CfnRuleGroup_VisibilityConfigProperty{
+				VisibilityConfig: &awswafv2.CfnRuleGroup_VisibilityConfigProperty{
 					SampledRequestsEnabled:   jsii.Bool(true),
 					CloudWatchMetricsEnabled: jsii.Bool(true),
 					MetricName:               jsii.String("AllowFromIPSet1"),
 				},
 			},
-			{
+			&awswafv2.CfnRuleGroup_RuleProperty{
 				Name:     jsii.String("AllowFromIPSet2"),
 				Priority: jsii.Number(2),
-				Statement: &awsWafv2.CfnRuleGroup_StatementOneProperty{
-					IpSetReferenceStatement: &awsWafv2.CfnRuleGroup_IpSetReferenceStatementProperty{
+				Statement: &awswafv2.CfnRuleGroup_StatementProperty{
+					IpSetReferenceStatement: &awswafv2.CfnRuleGroup_IPSetReferenceStatementProperty{
 						Arn: ipSet2.AttrArn(),
 					},
 				},
-				VisibilityConfig: &awsWafv2.CfnRuleGroup_VisibilityConfigProperty{
+				VisibilityConfig: &awswafv2.CfnRuleGroup_VisibilityConfigProperty{
 					SampledRequestsEnabled:   jsii.Bool(true),
 					CloudWatchMetricsEnabled: jsii.Bool(true),
 					MetricName:               jsii.String("AllowFromIPSet2"),
@@ -303,27 +435,27 @@ func (self *APIResources) createWAF(domainName string, api awsapigateway.IRestAp
 	})
 
 	// Create a WebACL
-	webACL := awsWafv2.NewCfnWebACL(self, jsii.String(domainName+"MyWebACL"), &awsWafv2.CfnWebACLProps{
+	webACL := awswafv2.NewCfnWebACL(self.Stack, jsii.String(domainName+"MyWebACL"), &awswafv2.CfnWebACLProps{
 		Description: jsii.String("API ACL for the " + domainName + " API Gateway"),
-		DefaultAction: &awsWafv2.CfnWebACL_DefaultActionProperty{
+		DefaultAction: &awswafv2.CfnWebACL_DefaultActionProperty{
 			Block: &map[string]interface{}{},
 		},
 		Scope: jsii.String("REGIONAL"),
-		VisibilityConfig: &awsWafv2.CfnWebACL_VisibilityConfigProperty{
+		VisibilityConfig: &awswafv2.CfnWebACL_VisibilityConfigProperty{
 			CloudWatchMetricsEnabled: jsii.Bool(true),
 			MetricName:               jsii.String("MyWebACLMetrics"),
 			SampledRequestsEnabled:   jsii.Bool(true),
 		},
-		Rules: []awsWafv2.CfnWebACL_RuleProperty{
-			{
+		Rules: &[]interface{}{
+			&awswafv2.CfnWebACL_RuleProperty{
 				Name:     jsii.String("IPRuleGroupRule"),
 				Priority: jsii.Number(1),
-				Statement: &awsWafv2.CfnWebACL_StatementOneProperty{
-					RuleGroupReferenceStatement: &awsWafv2.CfnWebACL_RuleGroupReferenceStatementProperty{
+				Statement: &awswafv2.CfnWebACL_StatementProperty{
+					RuleGroupReferenceStatement: &awswafv2.CfnWebACL_RuleGroupReferenceStatementProperty{
 						Arn: ruleGroup.AttrArn(),
 					},
 				},
-				VisibilityConfig: &awsWafv2.CfnWebACL_VisibilityConfigProperty{
+				VisibilityConfig: &awswafv2.CfnWebACL_VisibilityConfigProperty{
 					SampledRequestsEnabled:   jsii.Bool(true),
 					CloudWatchMetricsEnabled: jsii.Bool(true),
 					MetricName:               jsii.String("IPRuleGroupRule"),
@@ -336,8 +468,8 @@ func (self *APIResources) createWAF(domainName string, api awsapigateway.IRestAp
 	api.Node().AddDependency(webACL)
 
 	// Associate the WebACL with the stage
-	awsWafv2.NewCfnWebACLAssociation(self, jsii.String("WebACLAssociation"), &awsWafv2.CfnWebACLAssociationProps{
+	awswafv2.NewCfnWebACLAssociation(self.Stack, jsii.String("WebACLAssociation"), &awswafv2.CfnWebACLAssociationProps{
 		WebAclArn:   webACL.AttrArn(),
-		ResourceArn: *api.DeploymentStage().StageArn(),
+		ResourceArn: api.DeploymentStage().StageArn(),
 	})
 }
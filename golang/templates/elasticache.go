@@ -1,118 +1,176 @@
 package templates
 
 import (
-	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsautoscaling"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awselasticache"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awselasticloadbalancingv2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
-)
 
-func CreateElastiCache() {
-	// Create the app properly
-	app := awscdk.NewApp(nil)
+	"cdk-template/naming"
+)
 
-	// pass it as parameter or use this function as main
-	stack := awscdk.NewStack(app, jsii.String("StickySessionStack"), &awscdk.StackProps{
-		Env: &awscdk.Environment{
-			Account: jsii.String("YOUR_ACCOUNT_ID"),
-			Region:  jsii.String("YOUR_REGION"),
-		},
-	})
+type ElastiCacheProps struct {
+	Environment string
+	Config      *Config
+	// ExistingVpcProps default to a new VPC with public and private subnets in
+	// two AZs.
+	ExistingVpcProps
+	// CacheNodeType defaults to cache.t3.micro.
+	CacheNodeType string
+	// CertificateArn adds an HTTPS listener to the load balancer when set.
+	CertificateArn string
+}
 
-	// Create a VPC
-	vpc := awsec2.NewVpc(stack, jsii.String("MyVPC"), &awsec2.VpcProps{
-		MaxAzs: jsii.Number(2),
-		SubnetConfiguration: &[]*awsec2.SubnetConfiguration{
-			{
-				CidrMask:   jsii.Number(24),
-				Name:       jsii.String("Public"),
-				SubnetType: awsec2.SubnetType_PUBLIC,
-			},
-			{
-				CidrMask:   jsii.Number(24),
-				Name:       jsii.String("Private"),
-				SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS,
-			},
-		},
-	})
+// ElastiCache is a Redis cluster shared by an auto scaling group of web
+// servers behind an internet-facing load balancer, which keeps the sessions
+// in the cache instead of on the instances.
+type ElastiCache struct {
+	constructs.Construct
+	Vpc                awsec2.IVpc
+	CacheCluster       awselasticache.CfnCacheCluster
+	CacheSecurityGroup awsec2.SecurityGroup
+	LoadBalancer       awselasticloadbalancingv2.ApplicationLoadBalancer
+	AutoScalingGroup   awsautoscaling.AutoScalingGroup
+}
 
-	// Create a security group for Elasticache
-	cacheSecurityGroup := awsec2.NewSecurityGroup(stack, jsii.String("CacheSecurityGroup"), &awsec2.SecurityGroupProps{
-		Vpc:         vpc,
-		Description: jsii.String("Security group for Elasticache"),
-	})
-	cacheSecurityGroup.AddIngressRule(awsec2.Peer_Ipv4(vpc.VpcCidrBlock()), awsec2.Port_Tcp(jsii.Number(6379)), jsii.String("Allow inbound from VPC"), nil)
+func NewElastiCache(scope constructs.Construct, id string, props *ElastiCacheProps) *ElastiCache {
+	self := &ElastiCache{
+		Construct: constructs.NewConstruct(scope, &id),
+	}
+	config := requireConfig(self.Construct, props.Config)
+	names := naming.New(props.Environment, config.Project)
 
-	// Create the Elasticache cluster
-	cacheCluster := awselasticache.NewCfnCacheCluster(stack, jsii.String("MyCacheCluster"), &awselasticache.CfnCacheClusterProps{
-		CacheNodeType:       jsii.String("cache.t2.micro"),
-		Engine:              jsii.String("redis"),
-		NumCacheNodes:       jsii.Number(1),
-		VpcSecurityGroupIds: jsii.Strings(*cacheSecurityGroup.SecurityGroupId()),
-		ClusterName:         jsii.String("my-cache-cluster"),
-	})
+	self.Vpc = props.existingVpc(self.Construct)
+	if self.Vpc == nil {
+		self.Vpc = newVpc(self.Construct, "MyVPC", awsec2.VpcProps{
+			MaxAzs: jsii.Number(2),
+			SubnetConfiguration: &[]*awsec2.SubnetConfiguration{
+				{
+					CidrMask:   jsii.Number(24),
+					Name:       jsii.String("Public"),
+					SubnetType: awsec2.SubnetType_PUBLIC,
+				},
+				{
+					CidrMask:   jsii.Number(24),
+					Name:       jsii.String("Private"),
+					SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS,
+				},
+			},
+		})
+	}
 
-	subnets := vpc.PrivateSubnets()
-	var subnetIds []*string
-	for _, v := range *subnets {
-		subnetIds = append(subnetIds, v.SubnetId())
+	publicSubnets := &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PUBLIC}
+	privateSubnets := &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS}
+	validPublic := validSubnetSelection(self.Construct, self.Vpc, publicSubnets, "Load balancer")
+	validPrivate := validSubnetSelection(self.Construct, self.Vpc, privateSubnets, "Redis cluster and instances")
+	if !validPublic || !validPrivate {
+		return self
 	}
-	// Create a subnet group for the Elasticache cluster
-	redisSubnetGroup := awselasticache.NewCfnSubnetGroup(stack, jsii.String("RedisSubnetGroup"), &awselasticache.CfnSubnetGroupProps{
-		Description:          jsii.String("Subnet group for the Redis cluster"),
-		SubnetIds:            &subnetIds,
-		CacheSubnetGroupName: jsii.String("Redis-Subnet-Group"),
-	})
-	cacheCluster.AddDependsOn(redisSubnetGroup)
+
+	self.CacheSecurityGroup, self.CacheCluster = newRedisCluster(self.Construct, self.Vpc, names, id, props.CacheNodeType)
 
 	// Create an ALB in a public subnet
-	alb := awselasticloadbalancingv2.NewApplicationLoadBalancer(stack, jsii.String("MyALB"), &awselasticloadbalancingv2.ApplicationLoadBalancerProps{
-		Vpc:            vpc,
+	loadBalancerProps := &awselasticloadbalancingv2.ApplicationLoadBalancerProps{
+		Vpc:            self.Vpc,
 		InternetFacing: jsii.Bool(true),
-		VpcSubnets: &awsec2.SubnetSelection{
-			SubnetType: awsec2.SubnetType_PUBLIC,
-		},
-	})
+		VpcSubnets:     publicSubnets,
+	}
+	if isDualStack(self.Vpc) {
+		// The open listeners then also allow ::/0
+		loadBalancerProps.IpAddressType = awselasticloadbalancingv2.IpAddressType_DUAL_STACK
+	}
+	self.LoadBalancer = awselasticloadbalancingv2.NewApplicationLoadBalancer(self.Construct, jsii.String("MyALB"), loadBalancerProps)
 
 	// Create an IAM role for EC2 instances
-	instanceRole := awsiam.NewRole(stack, jsii.String("InstanceRole"), &awsiam.RoleProps{
+	instanceRole := awsiam.NewRole(self.Construct, jsii.String("InstanceRole"), &awsiam.RoleProps{
 		AssumedBy: awsiam.NewServicePrincipal(jsii.String("ec2.amazonaws.com"), nil),
 	})
 	instanceRole.AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("AmazonSSMManagedInstanceCore")))
 
 	// Create an Auto Scaling Group
-	asg := awsautoscaling.NewAutoScalingGroup(stack, jsii.String("MyAutoScalingGroup"), &awsautoscaling.AutoScalingGroupProps{
-		Vpc:             vpc,
-		InstanceType:    awsec2.InstanceType_Of(awsec2.InstanceClass_T2, awsec2.InstanceSize_MICRO),
+	self.AutoScalingGroup = awsautoscaling.NewAutoScalingGroup(self.Construct, jsii.String("MyAutoScalingGroup"), &awsautoscaling.AutoScalingGroupProps{
+		Vpc:             self.Vpc,
+		InstanceType:    awsec2.InstanceType_Of(awsec2.InstanceClass_T3, awsec2.InstanceSize_MICRO),
 		MachineImage:    awsec2.MachineImage_LatestAmazonLinux2(nil),
 		MinCapacity:     jsii.Number(2),
 		MaxCapacity:     jsii.Number(4),
 		DesiredCapacity: jsii.Number(2),
 		Role:            instanceRole,
-		VpcSubnets: &awsec2.SubnetSelection{
-			SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS,
-		},
+		VpcSubnets:      privateSubnets,
+	})
+
+	targets := []awselasticloadbalancingv2.IApplicationLoadBalancerTarget{self.AutoScalingGroup}
+
+	// Attach the instances to the ALB listeners
+	listener := self.LoadBalancer.AddListener(jsii.String("Listener"), &awselasticloadbalancingv2.BaseApplicationListenerProps{Port: jsii.Number(80)})
+	listener.AddTargets(jsii.String("TargetGroup"), &awselasticloadbalancingv2.AddApplicationTargetsProps{
+		Port:                       jsii.Number(80),
+		Targets:                    &targets,
+		LoadBalancingAlgorithmType: awselasticloadbalancingv2.TargetGroupLoadBalancingAlgorithmType_LEAST_OUTSTANDING_REQUESTS,
 	})
 
-	targets := []awselasticloadbalancingv2.IApplicationLoadBalancerTarget{}
-	targets = append(targets, asg)
-	// Create a target group for the ALB
-	awselasticloadbalancingv2.NewApplicationTargetGroup(stack, jsii.String("MyTargetGroup"), &awselasticloadbalancingv2.ApplicationTargetGroupProps{
-		Port:       jsii.Number(80),
-		Targets:    &targets,
-		Vpc:        vpc,
-		TargetType: awselasticloadbalancingv2.TargetType_INSTANCE,
+	if props.CertificateArn != "" {
+		httpsListener := self.LoadBalancer.AddListener(jsii.String("HTTPSListener"), &awselasticloadbalancingv2.BaseApplicationListenerProps{
+			Port:         jsii.Number(443),
+			Certificates: &[]awselasticloadbalancingv2.IListenerCertificate{awselasticloadbalancingv2.ListenerCertificate_FromArn(jsii.String(props.CertificateArn))},
+		})
+		httpsListener.AddTargets(jsii.String("HTTPSTargetGroup"), &awselasticloadbalancingv2.AddApplicationTargetsProps{
+			Port:    jsii.Number(80),
+			Targets: &targets,
+		})
+	}
+
+	ApplyStandardTags(self.Construct, config, props.Environment, "")
+
+	return self
+}
+
+// newRedisCluster creates a single node Redis cluster in the private subnets of
+// the VPC, reachable from the whole VPC. In dual-stack VPCs it also listens on
+// IPv6. component keeps the names of several clusters in one environment
+// apart.
+func newRedisCluster(scope constructs.Construct, vpc awsec2.IVpc, names naming.Namer, component string, nodeType string) (awsec2.SecurityGroup, awselasticache.CfnCacheCluster) {
+	dualStack := isDualStack(vpc)
+	if nodeType == "" {
+		// Nitro based nodes, which dual-stack clusters need
+		nodeType = "cache.t3.micro"
+	}
+
+	// Create a security group for Elasticache
+	cacheSecurityGroup := awsec2.NewSecurityGroup(scope, jsii.String("CacheSecurityGroup"), &awsec2.SecurityGroupProps{
+		Vpc:         vpc,
+		Description: jsii.String("Security group for Elasticache"),
 	})
+	allowFromVpc(cacheSecurityGroup, vpc, awsec2.Port_Tcp(jsii.Number(6379)), "Allow inbound from VPC")
 
-	// Attach the target group to the ALB listener
-	listener := alb.AddListener(jsii.String("Listener"), &awselasticloadbalancingv2.BaseApplicationListenerProps{Port: jsii.Number(80)})
-	httpslistener := alb.AddListener(jsii.String("HTTPSListener"), &awselasticloadbalancingv2.BaseApplicationListenerProps{Port: jsii.Number(443)})
-	listener.AddTargets(jsii.String("TargetGroup"), &awselasticloadbalancingv2.AddApplicationTargetsProps{Targets: &targets, LoadBalancingAlgorithmType: awselasticloadbalancingv2.TargetGroupLoadBalancingAlgorithmType_LEAST_OUTSTANDING_REQUESTS})
-	httpslistener.AddTargets(jsii.String("HTTPSTargetGroup"), &awselasticloadbalancingv2.AddApplicationTargetsProps{Targets: &targets})
+	subnets := vpc.PrivateSubnets()
+	var subnetIds []*string
+	for _, v := range *subnets {
+		subnetIds = append(subnetIds, v.SubnetId())
+	}
+	// Create a subnet group for the Elasticache cluster
+	redisSubnetGroup := awselasticache.NewCfnSubnetGroup(scope, jsii.String("RedisSubnetGroup"), &awselasticache.CfnSubnetGroupProps{
+		Description:          jsii.String("Subnet group for the Redis cluster"),
+		SubnetIds:            &subnetIds,
+		CacheSubnetGroupName: jsii.String(names.Name(naming.ElastiCacheSubnets, component, "redis")),
+	})
+
+	// Create the Elasticache cluster
+	cacheCluster := awselasticache.NewCfnCacheCluster(scope, jsii.String("MyCacheCluster"), &awselasticache.CfnCacheClusterProps{
+		CacheNodeType:        jsii.String(nodeType),
+		Engine:               jsii.String("redis"),
+		NumCacheNodes:        jsii.Number(1),
+		VpcSecurityGroupIds:  jsii.Strings(*cacheSecurityGroup.SecurityGroupId()),
+		CacheSubnetGroupName: redisSubnetGroup.Ref(),
+		ClusterName:          jsii.String(names.Name(naming.ElastiCacheCluster, component, "redis")),
+	})
+	if dualStack {
+		cacheCluster.SetNetworkType(jsii.String("dual_stack"))
+		cacheCluster.SetIpDiscovery(jsii.String("ipv4"))
+	}
 
-	// Synth with options if necessary
-	app.Synth(nil)
+	return cacheSecurityGroup, cacheCluster
 }
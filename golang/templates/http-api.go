@@ -0,0 +1,147 @@
+package templates
+
+import (
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigatewayv2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigatewayv2authorizers"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigatewayv2integrations"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53targets"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"cdk-template/naming"
+)
+
+// APIType selects the API Gateway flavour built from PropsAPIResources.
+type APIType string
+
+const (
+	// RestAPI builds an API Gateway REST API (the default).
+	RestAPI APIType = ""
+	// HTTPAPI builds a cheaper, lower latency API Gateway HTTP API.
+	HTTPAPI APIType = "http"
+)
+
+// JWTAuthorizerSettings protects HTTP API routes with a JWT issued by e.g. Cognito.
+type JWTAuthorizerSettings struct {
+	Issuer   string
+	Audience []string
+}
+
+// NewHTTPAPIResources is the HTTP API counterpart of NewAPIResources. It is
+// selected automatically when props.APIType is HTTPAPI.
+//
+// HTTP APIs have no usage plans, API keys, stage cache or WAF association, so
+// routes are protected by props.JWTAuthorizer instead. Without it the API is
+// public, which props.AllowUnauthenticated has to confirm. CertificateArn has
+// to point to a regional certificate.
+func NewHTTPAPIResources(scope constructs.Construct, id string, props *PropsAPIResources) *APIResources {
+	self := &APIResources{
+		Stack: awscdk.NewStack(scope, &id, &props.StackProps),
+	}
+	self.config = requireConfig(self.Stack, props.Config)
+	self.names = naming.New(props.Environment, self.config.Project)
+
+	domainName := props.DomainName
+	golangCodeAsset := "sample-code/golang-sample.zip"
+
+	lambdaFunction, _ := self.createLambdaFunctionAndRole(domainName, props, golangCodeAsset)
+
+	api := self.addHTTPAPIResources(props, lambdaFunction)
+	self.createObservability(props, api, lambdaFunction, "")
+	self.createSamplingRule(props)
+
+	ApplyStandardTags(self.Stack, self.config, props.Environment, props.ApiDomainName)
+
+	return self
+}
+
+func (self *APIResources) addHTTPAPIResources(props *PropsAPIResources, lambdaFunction awslambda.IFunction) awsapigatewayv2.HttpApi {
+	certificate := awscertificatemanager.Certificate_FromCertificateArn(self.Stack, jsii.Sprintf("%sCertificate", props.ApiDomainName), &props.CertificateArn)
+
+	apiGatewayDomainName := awsapigatewayv2.NewDomainName(self.Stack, jsii.Sprintf("%sApiGatewayDomainName", props.ApiDomainName), &awsapigatewayv2.DomainNameProps{
+		DomainName:  &props.ApiDomainName,
+		Certificate: certificate,
+	})
+
+	var authorizer awsapigatewayv2.IHttpRouteAuthorizer
+	switch {
+	case props.JWTAuthorizer != nil:
+		authorizer = awsapigatewayv2authorizers.NewHttpJwtAuthorizer(jsii.String(props.DomainName+"JwtAuthorizer"), &props.JWTAuthorizer.Issuer, &awsapigatewayv2authorizers.HttpJwtAuthorizerProps{
+			JwtAudience: jsii.Strings(props.JWTAuthorizer.Audience...),
+		})
+	case props.AllowUnauthenticated:
+		awscdk.Annotations_Of(self.Stack).AddWarning(jsii.String("The HTTP API has no authorizer, every route is public"))
+	default:
+		awscdk.Annotations_Of(self.Stack).AddError(jsii.String("HTTP APIs have no API key or WAF, set JWTAuthorizer or AllowUnauthenticated to make the API public"))
+	}
+
+	api := awsapigatewayv2.NewHttpApi(self.Stack, &props.ApiDomainName, &awsapigatewayv2.HttpApiProps{
+		ApiName:     jsii.String(props.ApiDomainName),
+		Description: jsii.String(props.ApiDomainName + " HTTP API for the " + props.Environment + " environment"),
+		CorsPreflight: &awsapigatewayv2.CorsPreflightOptions{
+			AllowHeaders: jsii.Strings("Content-Type", "X-Amz-Date", "Authorization", "X-Api-Key", "X-Amz-Security-Token"),
+			AllowMethods: &[]awsapigatewayv2.CorsHttpMethod{awsapigatewayv2.CorsHttpMethod_GET, awsapigatewayv2.CorsHttpMethod_POST, awsapigatewayv2.CorsHttpMethod_OPTIONS},
+			AllowOrigins: jsii.Strings("https://" + props.DomainName),
+		},
+		DefaultAuthorizer: authorizer,
+		DefaultDomainMapping: &awsapigatewayv2.DomainMappingOptions{
+			DomainName: apiGatewayDomainName,
+		},
+	})
+
+	// Same limits as the REST API usage plan
+	defaultStage := api.DefaultStage().Node().DefaultChild().(awsapigatewayv2.CfnStage)
+	defaultStage.SetDefaultRouteSettings(&awsapigatewayv2.CfnStage_RouteSettingsProperty{
+		ThrottlingRateLimit:  jsii.Number(2000),
+		ThrottlingBurstLimit: jsii.Number(1000),
+	})
+
+	lambdaIntegration := awsapigatewayv2integrations.NewHttpLambdaIntegration(jsii.String(props.DomainName+"LambdaIntegration"), lambdaFunction, nil)
+
+	api.AddRoutes(&awsapigatewayv2.AddRoutesOptions{
+		Path:        jsii.String("/save"),
+		Methods:     &[]awsapigatewayv2.HttpMethod{awsapigatewayv2.HttpMethod_POST},
+		Integration: lambdaIntegration,
+	})
+
+	for _, route := range props.Routes {
+		if !route.Integration.known() {
+			awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("Route %s %s: unknown integration type %q", route.Method, route.Path, route.Integration))
+			continue
+		}
+		if route.Integration != LambdaIntegration {
+			awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("Route %s %s: the %q integration is only available on REST APIs", route.Method, route.Path, route.Integration))
+			continue
+		}
+		if route.Cache != nil {
+			awscdk.Annotations_Of(self.Stack).AddWarning(jsii.Sprintf("Route %s %s: HTTP APIs have no stage cache, the cache settings are ignored", route.Method, route.Path))
+		}
+		api.AddRoutes(&awsapigatewayv2.AddRoutesOptions{
+			Path:        jsii.String("/" + strings.Trim(route.Path, "/")),
+			Methods:     &[]awsapigatewayv2.HttpMethod{awsapigatewayv2.HttpMethod(strings.ToUpper(route.Method))},
+			Integration: lambdaIntegration,
+		})
+	}
+
+	hostedZone := awsroute53.HostedZone_FromHostedZoneAttributes(self.Stack, jsii.String("HZA"+props.ApiDomainName), &awsroute53.HostedZoneAttributes{
+		HostedZoneId: &props.HostedZoneId,
+		ZoneName:     &props.ApiDomainName,
+	})
+
+	// Create a A record in Route 53 for the custom domain name
+	awsroute53.NewARecord(self.Stack, jsii.String("ARecord"+props.ApiDomainName), &awsroute53.ARecordProps{
+		Zone:           hostedZone,
+		RecordName:     &props.ApiDomainName,
+		Target:         awsroute53.RecordTarget_FromAlias(awsroute53targets.NewApiGatewayv2DomainProperties(apiGatewayDomainName.RegionalDomainName(), apiGatewayDomainName.RegionalHostedZoneId())),
+		Comment:        jsii.String("HTTP API alias record for " + props.DomainName),
+		DeleteExisting: jsii.Bool(true),
+	})
+
+	return api
+}
@@ -0,0 +1,30 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestHTTPAPIAuthorization(t *testing.T) {
+	t.Run("without authorizer", func(t *testing.T) {
+		props := apiTestProps()
+		props.APIType = HTTPAPI
+		stack := NewAPIResources(awscdk.NewApp(nil), "http-api", props).Stack
+
+		assertAnnotationErrors(t, stack, "HTTP APIs have no API key or WAF, set JWTAuthorizer or AllowUnauthenticated")
+	})
+
+	t.Run("public", func(t *testing.T) {
+		props := apiTestProps()
+		props.APIType = HTTPAPI
+		props.AllowUnauthenticated = true
+		stack := NewAPIResources(awscdk.NewApp(nil), "http-api", props).Stack
+
+		assertAnnotationErrors(t, stack)
+		template := assertions.Template_FromStack(stack, nil)
+		template.ResourceCountIs(jsii.String("AWS::ApiGatewayV2::Authorizer"), jsii.Number(0))
+	})
+}
@@ -0,0 +1,87 @@
+package templates
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssecretsmanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsssm"
+	"github.com/aws/jsii-runtime-go"
+)
+
+var environmentVariablePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// LambdaSecretsSettings gives the API Lambda read access to secrets and
+// parameters that already exist. Only their names reach the template and the
+// function's environment, the values are read at runtime.
+type LambdaSecretsSettings struct {
+	// Secrets maps an environment variable to the name of a Secrets Manager secret.
+	Secrets map[string]string
+	// Parameters maps an environment variable to the name of an SSM parameter,
+	// SecureString parameters encrypted with the default aws/ssm key included.
+	Parameters map[string]string
+	// Extension attaches the AWS Parameters and Secrets Lambda Extension, which
+	// serves cached values on localhost:2773.
+	Extension bool
+}
+
+// applySecrets grants the role read access to the configured secrets and
+// parameters only and passes their names to the function.
+func (self *APIResources) applySecrets(functionProps *awslambda.FunctionProps, settings *LambdaSecretsSettings, role awsiam.IRole) {
+	if settings == nil {
+		return
+	}
+
+	environment := *functionProps.Environment
+
+	// Sorted so that the policy statements keep their order between synths
+	for _, variable := range sortedKeys(settings.Secrets) {
+		if !self.validSecretVariable(environment, variable) {
+			continue
+		}
+		secret := awssecretsmanager.Secret_FromSecretNameV2(self.Stack, jsii.String("Secret"+variable), jsii.String(settings.Secrets[variable]))
+		secret.GrantRead(role, nil)
+		environment[variable] = secret.SecretName()
+	}
+
+	for _, variable := range sortedKeys(settings.Parameters) {
+		if !self.validSecretVariable(environment, variable) {
+			continue
+		}
+		parameter := awsssm.StringParameter_FromStringParameterName(self.Stack, jsii.String("Parameter"+variable), jsii.String(settings.Parameters[variable]))
+		parameter.GrantRead(role)
+		environment[variable] = parameter.ParameterName()
+	}
+
+	functionProps.Environment = &environment
+
+	if settings.Extension {
+		functionProps.ParamsAndSecrets = awslambda.ParamsAndSecretsLayerVersion_FromVersion(awslambda.ParamsAndSecretsVersions_V1_0_103, &awslambda.ParamsAndSecretsOptions{
+			CacheEnabled: jsii.Bool(true),
+		})
+	}
+}
+
+func (self *APIResources) validSecretVariable(environment map[string]*string, variable string) bool {
+	if !environmentVariablePattern.MatchString(variable) {
+		awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("%q is not a valid environment variable name", variable))
+		return false
+	}
+	if _, ok := environment[variable]; ok {
+		awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("Environment variable %s is already set on the Lambda function", variable))
+		return false
+	}
+	return true
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,166 @@
+package templates
+
+import (
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// LambdaVPCSettings attaches the API Lambda to an existing VPC so it can reach
+// RDS or ElastiCache in private subnets.
+type LambdaVPCSettings struct {
+	// Vpc is an existing VPC, e.g. from awsec2.Vpc_FromLookup or another stack.
+	Vpc awsec2.IVpc
+	// Subnets defaults to the private subnets with egress.
+	Subnets *awsec2.SubnetSelection
+	// ExistingEndpoints are the VPC endpoints already present in the VPC, keyed
+	// by service name ("s3", "sns", "secretsmanager", "ssm"). CDK can't look
+	// endpoints up, so every endpoint of the VPC has to be declared here.
+	ExistingEndpoints map[string]awsec2.IVpcEndpoint
+	// CreateEndpoints creates the endpoints the function needs that are not
+	// in ExistingEndpoints. Without it the function reaches them through the
+	// NAT of the VPC. A second interface endpoint with private DNS for a
+	// service fails to deploy, so only set it when the VPC has none. The
+	// policies of the endpoints only admit the bucket, topic, secrets and
+	// parameters of the function, other workloads in the subnets can't use
+	// them.
+	CreateEndpoints bool
+}
+
+func (settings *LambdaVPCSettings) subnets() *awsec2.SubnetSelection {
+	if settings.Subnets != nil {
+		return settings.Subnets
+	}
+	return &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS}
+}
+
+// applyVPC places the function in the VPC behind a security group owned by the
+// stack.
+func (self *APIResources) applyVPC(functionProps *awslambda.FunctionProps, props *PropsAPIResources, role awsiam.IRole) {
+	if props.VPC == nil {
+		return
+	}
+	if props.VPC.Vpc == nil {
+		awscdk.Annotations_Of(self.Stack).AddError(jsii.String("VPC.Vpc is required to attach the Lambda function to a VPC"))
+		return
+	}
+	if !validSubnetSelection(self.Stack, props.VPC.Vpc, props.VPC.subnets(), "Lambda function") {
+		return
+	}
+
+	self.LambdaSecurityGroup = awsec2.NewSecurityGroup(self.Stack, jsii.String("LambdaSecurityGroup"), &awsec2.SecurityGroupProps{
+		Vpc:              props.VPC.Vpc,
+		Description:      jsii.String(props.Environment + " " + props.DomainName + " Lambda function"),
+		AllowAllOutbound: jsii.Bool(true),
+	})
+
+	// CDK only adds the ENI permissions to roles it creates for the function
+	role.AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("service-role/AWSLambdaVPCAccessExecutionRole")))
+
+	functionProps.Vpc = props.VPC.Vpc
+	functionProps.VpcSubnets = props.VPC.subnets()
+	functionProps.SecurityGroups = &[]awsec2.ISecurityGroup{self.LambdaSecurityGroup}
+}
+
+// lambdaEndpoints are the endpoints of the services the function calls. Their
+// policies only admit the account of the stack to the bucket, topic, secrets
+// and parameters of the function.
+func (self *APIResources) lambdaEndpoints(props *PropsAPIResources, bucketName string) []EndpointSpec {
+	account := &EndpointConditions{PrincipalAccounts: []string{*awscdk.Aws_ACCOUNT_ID()}}
+	// Interface endpoints take a single subnet per availability zone
+	endpointSubnets := *props.VPC.subnets()
+	endpointSubnets.OnePerAz = jsii.Bool(true)
+
+	endpoints := []EndpointSpec{
+		// S3 is reached through a gateway endpoint, it is free and needs no security group
+		{
+			Service:          "s3",
+			Type:             GatewayEndpoint,
+			Subnets:          props.VPC.subnets(),
+			PolicyStatements: []awsiam.PolicyStatement{S3BucketsStatement([]string{bucketName}, nil, account)},
+		},
+		{
+			Service:          "sns",
+			Subnets:          &endpointSubnets,
+			PolicyStatements: []awsiam.PolicyStatement{SNSTopicsStatement([]string{*self.deadLetterTopic.TopicName()}, nil, account)},
+		},
+	}
+	if props.Secrets == nil {
+		return endpoints
+	}
+	if len(props.Secrets.Secrets) > 0 {
+		var secrets []string
+		for _, variable := range sortedKeys(props.Secrets.Secrets) {
+			secrets = append(secrets, props.Secrets.Secrets[variable])
+		}
+		endpoints = append(endpoints, EndpointSpec{
+			Service:          "secretsmanager",
+			Subnets:          &endpointSubnets,
+			PolicyStatements: []awsiam.PolicyStatement{SecretsStatement(secrets, nil, account)},
+		})
+	}
+	if len(props.Secrets.Parameters) > 0 {
+		var parameters []string
+		for _, variable := range sortedKeys(props.Secrets.Parameters) {
+			parameters = append(parameters, props.Secrets.Parameters[variable])
+		}
+		endpoints = append(endpoints, EndpointSpec{
+			Service:          "ssm",
+			Subnets:          &endpointSubnets,
+			PolicyStatements: []awsiam.PolicyStatement{SSMParametersStatement(parameters, nil, account)},
+		})
+	}
+	return endpoints
+}
+
+// addLambdaEndpoints gives the function a private route to the services it
+// calls through the existing endpoints, creating the missing ones when
+// CreateEndpoints is set.
+func (self *APIResources) addLambdaEndpoints(props *PropsAPIResources, lambdaFunction awslambda.IFunction, bucketName string) {
+	// applyVPC left the function out of the VPC
+	if self.LambdaSecurityGroup == nil {
+		return
+	}
+
+	httpsPort := float64(443)
+	var missing []string
+
+	for _, spec := range self.lambdaEndpoints(props, bucketName) {
+		if existing, ok := props.VPC.ExistingEndpoints[spec.Service]; ok {
+			// The ingress rules live in this stack so the endpoint's stack never
+			// references the Lambda security group
+			if connectable, ok := existing.(awsec2.IConnectable); ok {
+				for index, securityGroup := range *connectable.Connections().SecurityGroups() {
+					awsec2.NewCfnSecurityGroupIngress(self.Stack, jsii.Sprintf("%sIngress%d", endpointID(spec.Service), index+1), &awsec2.CfnSecurityGroupIngressProps{
+						GroupId:               securityGroup.SecurityGroupId(),
+						IpProtocol:            jsii.String("tcp"),
+						FromPort:              jsii.Number(httpsPort),
+						ToPort:                jsii.Number(httpsPort),
+						SourceSecurityGroupId: self.LambdaSecurityGroup.SecurityGroupId(),
+						Description:           jsii.String("HTTPS from the " + props.DomainName + " Lambda function"),
+					})
+				}
+			}
+			continue
+		}
+		if !props.VPC.CreateEndpoints {
+			missing = append(missing, spec.Service)
+			continue
+		}
+
+		endpoint := createEndpoint(self.Stack, props.VPC.Vpc, spec, nil)
+		if endpoint, ok := endpoint.(awsec2.InterfaceVpcEndpoint); ok {
+			endpoint.Connections().AllowFrom(lambdaFunction, awsec2.Port_Tcp(&httpsPort), jsii.String("HTTPS from the "+props.DomainName+" Lambda function"))
+		}
+	}
+
+	if len(missing) > 0 {
+		awscdk.Annotations_Of(self.Stack).AddWarning(jsii.Sprintf(
+			"Lambda function: no VPC endpoints for %s in VPC.ExistingEndpoints, the function reaches them through the NAT of the VPC unless VPC.CreateEndpoints is set",
+			strings.Join(missing, ", ")))
+	}
+}
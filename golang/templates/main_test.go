@@ -0,0 +1,81 @@
+package templates
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/jsii-runtime-go"
+)
+
+var testEnvironment = &awscdk.Environment{
+	Account: jsii.String("123456789012"),
+	Region:  jsii.String("us-east-1"),
+}
+
+var testConfig = &Config{Author: "Author", Project: "Template"}
+
+func apiTestProps() *PropsAPIResources {
+	return &PropsAPIResources{
+		StackProps:       awscdk.StackProps{Env: testEnvironment},
+		DomainName:       "example.com",
+		Environment:      "dev",
+		SampleCodeBucket: "example.com-sample-code",
+		CertificateArn:   "arn:aws:acm:us-east-1:123456789012:certificate/00000000-0000-0000-0000-000000000000",
+		HostedZoneId:     "Z0000000000000000000",
+		ApiDomainName:    "api.example.com",
+		Config:           testConfig,
+	}
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	// The templates package their Lambda code relative to the module root
+	if err := os.Chdir(".."); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	jsii.Close()
+	os.Exit(code)
+}
+
+// annotationErrors synthesizes the stack and returns its error messages,
+// prefixed with the path of the construct that reported them.
+func annotationErrors(stack awscdk.Stack) []string {
+	var messages []string
+	errors := assertions.Annotations_FromStack(stack).FindError(jsii.String("*"), assertions.Match_AnyValue())
+	for _, message := range *errors {
+		messages = append(messages, fmt.Sprintf("%s: %v", *message.Id, message.Entry.Data))
+	}
+	return messages
+}
+
+// assertAnnotationErrors fails unless every one of wants is part of an error
+// of the stack, and reports the errors nobody asked for.
+func assertAnnotationErrors(t *testing.T, stack awscdk.Stack, wants ...string) {
+	t.Helper()
+	messages := annotationErrors(stack)
+	matched := make([]bool, len(messages))
+	for _, want := range wants {
+		found := false
+		for index, message := range messages {
+			if strings.Contains(message, want) {
+				found, matched[index] = true, true
+			}
+		}
+		if !found {
+			t.Errorf("no error contains %q", want)
+		}
+	}
+	for index, message := range messages {
+		if !matched[index] {
+			t.Errorf("unexpected error %s", message)
+		}
+	}
+}
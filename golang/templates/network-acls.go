@@ -0,0 +1,319 @@
+package templates
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+const (
+	firstACLRuleNumber = 100
+	aclRuleNumberStep  = 10
+	// Default quota of rules per direction of a network ACL
+	maxACLRules = 20
+)
+
+// NetworkACLRule allows or denies TCP traffic between the subnets of a rule
+// set and the peers. Network ACLs are stateless, so allow rules also allow
+// the responses on the ephemeral ports 1024-65535 in the other direction.
+type NetworkACLRule struct {
+	Deny bool
+	// Egress rules match the traffic leaving the subnets, the default is the
+	// incoming traffic.
+	Egress bool
+	// Ports are TCP ports, no ports means all traffic.
+	Ports []int
+	// CIDRs are IPv4 or IPv6 blocks.
+	CIDRs []string
+	// SubnetGroups are peers by subnet group name, e.g. Private. Only their
+	// IPv4 blocks are used.
+	SubnetGroups []string
+}
+
+// NetworkACLRuleSet replaces the default network ACL of a subnet group. The
+// rules are numbered in order, traffic they don't allow is denied.
+type NetworkACLRuleSet struct {
+	SubnetGroup string
+	// DenyCIDRs are denied in both directions before any rule.
+	DenyCIDRs []string
+	Rules     []NetworkACLRule
+}
+
+// DatabaseSubnetsACL lets the private subnets reach PostgreSQL and Redis in
+// the isolated subnets, which can't be reached otherwise.
+func DatabaseSubnetsACL(denyCIDRs ...string) NetworkACLRuleSet {
+	return NetworkACLRuleSet{
+		SubnetGroup: "Isolated",
+		DenyCIDRs:   denyCIDRs,
+		Rules: []NetworkACLRule{
+			{Ports: []int{5432, 6379}, SubnetGroups: []string{"Private"}},
+		},
+	}
+}
+
+// aclEntry is a numbered entry of a network ACL.
+type aclEntry struct {
+	number int
+	egress bool
+	deny   bool
+	cidr   string
+	// within is a known block containing cidr when cidr is a token, e.g. the
+	// VPC CIDR for the blocks of its subnets
+	within string
+	// peer names cidr in messages when it is a token
+	peer     string
+	fromPort int
+	// toPort 0 is all traffic
+	toPort int
+	// response allows the responses to the traffic of an allow rule
+	response bool
+	// rule names the rule of the set the entry comes from in messages
+	rule string
+}
+
+func (entry aclEntry) direction() string {
+	if entry.egress {
+		return "egress"
+	}
+	return "ingress"
+}
+
+func (entry aclEntry) id() string {
+	if entry.egress {
+		return fmt.Sprintf("Egress%d", entry.number)
+	}
+	return fmt.Sprintf("Ingress%d", entry.number)
+}
+
+// String describes the entry for messages, e.g. rule 2 (ingress 110, allow
+// port 5432 from 10.0.0.0/8).
+func (entry aclEntry) String() string {
+	action, peer := "allow", "from"
+	if entry.deny {
+		action = "deny"
+	}
+	if entry.egress {
+		peer = "to"
+	}
+	cidr := entry.cidr
+	if entry.peer != "" {
+		cidr = entry.peer
+	}
+	return fmt.Sprintf("%s (%s %d, %s %s %s %s)", entry.rule, entry.direction(), entry.number, action, entry.ports(), peer, cidr)
+}
+
+func (entry aclEntry) ports() string {
+	switch {
+	case entry.toPort == 0:
+		return "all traffic"
+	case entry.fromPort == entry.toPort:
+		return fmt.Sprintf("port %d", entry.fromPort)
+	}
+	return fmt.Sprintf("ports %d-%d", entry.fromPort, entry.toPort)
+}
+
+// covers reports whether entry matches all traffic of other, which never
+// reaches other then.
+func (entry aclEntry) covers(other aclEntry) bool {
+	if entry.egress != other.egress {
+		return false
+	}
+	if entry.toPort != 0 && (other.toPort == 0 || other.fromPort < entry.fromPort || other.toPort > entry.toPort) {
+		return false
+	}
+	if entry.cidr == other.cidr {
+		return true
+	}
+	// Tokens, e.g. the blocks of the subnets, are only covered by blocks
+	// containing their known block and never cover anything else
+	prefix, err := netip.ParsePrefix(entry.cidr)
+	if err != nil {
+		return false
+	}
+	otherPrefix, err := netip.ParsePrefix(other.cidr)
+	if err != nil {
+		if otherPrefix, err = netip.ParsePrefix(other.within); err != nil {
+			return false
+		}
+	}
+	return prefix.Bits() <= otherPrefix.Bits() && prefix.Contains(otherPrefix.Addr())
+}
+
+// aclEntries numbers the entries of a rule set, ingress and egress separately.
+type aclEntries struct {
+	entries []aclEntry
+	next    map[bool]int
+}
+
+func (entries *aclEntries) add(entry aclEntry) {
+	if entries.next == nil {
+		entries.next = map[bool]int{false: firstACLRuleNumber, true: firstACLRuleNumber}
+	}
+	entry.number = entries.next[entry.egress]
+	entries.next[entry.egress] += aclRuleNumberStep
+	entries.entries = append(entries.entries, entry)
+}
+
+// addNetworkACLs attaches a network ACL to the subnet group of every rule set.
+// vpcCidr is empty for IPAM allocations, the entries of subnet group peers
+// can't be checked against the deny-list then.
+func (self *NetworkStack) addNetworkACLs(ruleSets []NetworkACLRuleSet, vpcCidr string) {
+	if len(ruleSets) == 0 {
+		return
+	}
+	if isDualStack(self.Vpc) {
+		awscdk.Annotations_Of(self.Stack).AddWarning(jsii.String("Network ACL rule sets only allow the IPv6 traffic of their IPv6 CIDRs"))
+	}
+
+	self.NetworkACLs = map[string]awsec2.NetworkAcl{}
+	seen := map[string]bool{}
+	for _, ruleSet := range ruleSets {
+		if seen[ruleSet.SubnetGroup] {
+			awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("Subnet group %s has more than one network ACL rule set", ruleSet.SubnetGroup))
+			continue
+		}
+		seen[ruleSet.SubnetGroup] = true
+
+		selection := &awsec2.SubnetSelection{SubnetGroupName: jsii.String(ruleSet.SubnetGroup)}
+		purpose := "Network ACL of " + ruleSet.SubnetGroup
+		if !validSubnetSelection(self.Stack, self.Vpc, selection, purpose) {
+			continue
+		}
+		entries, ok := self.networkACLEntries(ruleSet, purpose, vpcCidr)
+		if !checkNetworkACLEntries(self.Stack, purpose, entries) || !ok {
+			continue
+		}
+
+		acl := awsec2.NewNetworkAcl(self.Stack, jsii.String(ruleSet.SubnetGroup+"NetworkAcl"), &awsec2.NetworkAclProps{
+			Vpc:             self.Vpc,
+			SubnetSelection: selection,
+		})
+		for _, entry := range entries {
+			options := &awsec2.CommonNetworkAclEntryOptions{
+				RuleNumber: jsii.Number(entry.number),
+				Direction:  awsec2.TrafficDirection_INGRESS,
+				RuleAction: awsec2.Action_ALLOW,
+				Traffic:    awsec2.AclTraffic_AllTraffic(),
+				Cidr:       awsec2.AclCidr_Ipv4(jsii.String(entry.cidr)),
+			}
+			if entry.egress {
+				options.Direction = awsec2.TrafficDirection_EGRESS
+			}
+			if entry.deny {
+				options.RuleAction = awsec2.Action_DENY
+			}
+			if entry.toPort != 0 {
+				options.Traffic = awsec2.AclTraffic_TcpPortRange(jsii.Number(entry.fromPort), jsii.Number(entry.toPort))
+			}
+			if strings.Contains(entry.cidr, ":") {
+				options.Cidr = awsec2.AclCidr_Ipv6(jsii.String(entry.cidr))
+			}
+			acl.AddEntry(jsii.String(entry.id()), options)
+		}
+		self.NetworkACLs[ruleSet.SubnetGroup] = acl
+	}
+}
+
+// networkACLEntries expands the rule set into numbered entries.
+func (self *NetworkStack) networkACLEntries(ruleSet NetworkACLRuleSet, purpose string, vpcCidr string) ([]aclEntry, bool) {
+	entries := &aclEntries{}
+	valid := true
+
+	for _, cidr := range ruleSet.DenyCIDRs {
+		entries.add(aclEntry{deny: true, cidr: cidr, rule: "deny-list"})
+		entries.add(aclEntry{egress: true, deny: true, cidr: cidr, rule: "deny-list"})
+	}
+
+	for index, rule := range ruleSet.Rules {
+		name := fmt.Sprintf("rule %d", index+1)
+		if len(rule.CIDRs) == 0 && len(rule.SubnetGroups) == 0 {
+			awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("%s %s: no CIDRs or subnet groups", purpose, name))
+			valid = false
+			continue
+		}
+
+		var peers []aclEntry
+		for _, cidr := range rule.CIDRs {
+			peers = append(peers, aclEntry{cidr: cidr})
+		}
+		for _, group := range rule.SubnetGroups {
+			selection := &awsec2.SubnetSelection{SubnetGroupName: jsii.String(group)}
+			if !validSubnetSelection(self.Stack, self.Vpc, selection, purpose+" "+name) {
+				valid = false
+				continue
+			}
+			for index, subnet := range *self.Vpc.SelectSubnets(selection).Subnets {
+				peers = append(peers, aclEntry{cidr: *subnet.Ipv4CidrBlock(), within: vpcCidr, peer: fmt.Sprintf("%s subnet %d", group, index+1)})
+			}
+		}
+
+		ports := [][2]int{{0, 0}}
+		if len(rule.Ports) > 0 {
+			ports = nil
+			for _, port := range rule.Ports {
+				if port < 1 || port > 65535 {
+					awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("%s %s: invalid port %d", purpose, name, port))
+					valid = false
+				}
+				ports = append(ports, [2]int{port, port})
+			}
+		}
+
+		for _, peer := range peers {
+			for _, port := range ports {
+				entry := peer
+				entry.egress, entry.deny, entry.fromPort, entry.toPort, entry.rule = rule.Egress, rule.Deny, port[0], port[1], name
+				entries.add(entry)
+			}
+			if rule.Deny {
+				continue
+			}
+			// The responses to the peers
+			response := peer
+			response.egress, response.response, response.rule = !rule.Egress, true, name+" responses"
+			if len(rule.Ports) > 0 {
+				response.fromPort, response.toPort = 1024, 65535
+			}
+			entries.add(response)
+		}
+	}
+
+	return entries.entries, valid
+}
+
+// checkNetworkACLEntries reports rules that never match because an entry
+// with a lower number matches all their traffic. The responses overlap with
+// the rules by design, they are only reported when they shadow a deny rule.
+func checkNetworkACLEntries(scope awscdk.Stack, purpose string, entries []aclEntry) bool {
+	valid := true
+	counts := map[bool]int{}
+	for index, entry := range entries {
+		counts[entry.egress]++
+		if entry.response {
+			continue
+		}
+		for _, earlier := range entries[:index] {
+			if !earlier.covers(entry) || (earlier.response && !entry.deny) {
+				continue
+			}
+			conflict := "duplicates"
+			if earlier.deny != entry.deny {
+				conflict = "is shadowed by"
+			}
+			awscdk.Annotations_Of(scope).AddError(jsii.Sprintf("%s: %s %s %s", purpose, entry, conflict, earlier))
+			valid = false
+			break
+		}
+	}
+	for _, egress := range []bool{false, true} {
+		if counts[egress] > maxACLRules {
+			direction := aclEntry{egress: egress}.direction()
+			awscdk.Annotations_Of(scope).AddWarning(jsii.Sprintf("%s has %d %s rules, more than the default quota of %d", purpose, counts[egress], direction, maxACLRules))
+		}
+	}
+	return valid
+}
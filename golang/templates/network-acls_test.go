@@ -0,0 +1,47 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+)
+
+func TestNetworkACLConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		ruleSets []NetworkACLRuleSet
+		wants    []string
+	}{
+		{"deny-list outside the VPC", []NetworkACLRuleSet{DatabaseSubnetsACL("203.0.113.0/24", "10.1.0.0/16")}, nil},
+		{"deny-list covering the VPC", []NetworkACLRuleSet{DatabaseSubnetsACL("10.0.0.0/8")}, []string{
+			"Network ACL of Isolated: rule 1 (ingress 110, allow port 5432 from Private subnet 1) is shadowed by deny-list (ingress 100, deny all traffic from 10.0.0.0/8)",
+			"Network ACL of Isolated: rule 1 (ingress 120, allow port 6379 from Private subnet 1) is shadowed by deny-list (ingress 100, deny all traffic from 10.0.0.0/8)",
+			"Network ACL of Isolated: rule 1 (ingress 130, allow port 5432 from Private subnet 2) is shadowed by deny-list (ingress 100, deny all traffic from 10.0.0.0/8)",
+			"Network ACL of Isolated: rule 1 (ingress 140, allow port 6379 from Private subnet 2) is shadowed by deny-list (ingress 100, deny all traffic from 10.0.0.0/8)",
+		}},
+		{"duplicate and shadowed rules", []NetworkACLRuleSet{{
+			SubnetGroup: "Private",
+			Rules: []NetworkACLRule{
+				{Ports: []int{443}, CIDRs: []string{"10.1.0.0/16"}},
+				{Ports: []int{443}, CIDRs: []string{"10.1.2.0/24"}},
+				{Deny: true, Egress: true, Ports: []int{2049}, CIDRs: []string{"10.1.0.0/16"}},
+			},
+		}}, []string{
+			"Network ACL of Private: rule 2 (ingress 110, allow port 443 from 10.1.2.0/24) duplicates rule 1 (ingress 100, allow port 443 from 10.1.0.0/16)",
+			"Network ACL of Private: rule 3 (egress 120, deny port 2049 to 10.1.0.0/16) is shadowed by rule 1 responses (egress 100, allow ports 1024-65535 to 10.1.0.0/16)",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := NewNetworkStack(awscdk.NewApp(nil), "network", &NetworkStackProps{
+				StackProps:  awscdk.StackProps{Env: testEnvironment},
+				Environment: "dev",
+				Config:      testConfig,
+				NetworkACLs: test.ruleSets,
+			})
+
+			assertAnnotationErrors(t, network.Stack, test.wants...)
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package templates
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// TransitGatewaySettings attach the VPC to an existing transit gateway, e.g.
+// one shared with the account through AWS RAM.
+type TransitGatewaySettings struct {
+	TransitGatewayId string
+	// SubnetGroup holds the network interfaces of the attachment, one per AZ.
+	// Defaults to Private, or Isolated for VPCs without private subnets.
+	SubnetGroup string
+	// ApplianceMode keeps both directions of a flow in the same AZ, which
+	// stateful appliances in an inspection VPC need.
+	ApplianceMode bool
+	// AssociationRouteTableId is the transit gateway route table used for the
+	// traffic of the VPC. Defaults to the default route table of the gateway.
+	AssociationRouteTableId string
+	// PropagationRouteTableIds are the transit gateway route tables that learn
+	// the CIDR of the VPC. Association and propagation need the account that
+	// owns the transit gateway.
+	PropagationRouteTableIds []string
+	// Routes are the CIDRs routed through the transit gateway from the private
+	// and isolated subnets, e.g. the shared-services VPC.
+	Routes []string
+}
+
+// VpcPeeringSettings peer the VPC with another VPC. The routes to PeerCidr go
+// to the private and isolated subnets, the peer adds the routes back.
+type VpcPeeringSettings struct {
+	// Name identifies the peering in the stack, e.g. SharedServices.
+	Name      string
+	PeerVpcId string
+	PeerCidr  string
+	// PeerRegion defaults to the region of the stack.
+	PeerRegion string
+	// PeerOwnerId is the account of the peer VPC, defaults to the account of
+	// the stack. Other accounts need PeerRoleArn.
+	PeerOwnerId string
+	// PeerRoleArn is a role in the peer account that the VPC peering service
+	// assumes to accept the connection. It needs ec2:AcceptVpcPeeringConnection
+	// and to trust the account of the stack.
+	PeerRoleArn string
+}
+
+// addTransitGateway attaches the VPC to the transit gateway of settings.
+func (self *NetworkStack) addTransitGateway(settings *TransitGatewaySettings, routes *vpcRoutes) {
+	if settings == nil {
+		return
+	}
+	stack := self.Stack
+	if !strings.HasPrefix(settings.TransitGatewayId, "tgw-") {
+		awscdk.Annotations_Of(stack).AddError(jsii.Sprintf("Invalid transit gateway id %q", settings.TransitGatewayId))
+		return
+	}
+
+	subnetGroup := settings.SubnetGroup
+	if subnetGroup == "" {
+		subnetGroup = "Private"
+		if len(*self.Vpc.PrivateSubnets()) == 0 {
+			subnetGroup = "Isolated"
+		}
+	}
+	selection := &awsec2.SubnetSelection{SubnetGroupName: jsii.String(subnetGroup), OnePerAz: jsii.Bool(true)}
+	valid := validSubnetSelection(stack, self.Vpc, selection, "Transit gateway attachment")
+	var destinations []string
+	for _, cidr := range settings.Routes {
+		destination, ok := routes.destination(cidr, "transit gateway")
+		valid = valid && ok
+		destinations = append(destinations, destination)
+	}
+	if !valid {
+		return
+	}
+
+	// Options are untyped in CloudFormation, the keys are passed as is
+	options := map[string]interface{}{}
+	if settings.ApplianceMode {
+		options["ApplianceModeSupport"] = "enable"
+	}
+	if isDualStack(self.Vpc) {
+		options["Ipv6Support"] = "enable"
+	}
+	props := &awsec2.CfnTransitGatewayAttachmentProps{
+		TransitGatewayId: jsii.String(settings.TransitGatewayId),
+		VpcId:            self.Vpc.VpcId(),
+		SubnetIds:        self.Vpc.SelectSubnets(selection).SubnetIds,
+	}
+	if len(options) > 0 {
+		props.Options = options
+	}
+	self.TransitGatewayAttachment = awsec2.NewCfnTransitGatewayAttachment(stack, jsii.String("TransitGatewayAttachment"), props)
+
+	attachmentId := self.TransitGatewayAttachment.AttrId()
+	if settings.AssociationRouteTableId != "" {
+		awsec2.NewCfnTransitGatewayRouteTableAssociation(stack, jsii.String("TransitGatewayAssociation"), &awsec2.CfnTransitGatewayRouteTableAssociationProps{
+			TransitGatewayAttachmentId: attachmentId,
+			TransitGatewayRouteTableId: jsii.String(settings.AssociationRouteTableId),
+		})
+	}
+	for index, routeTableId := range settings.PropagationRouteTableIds {
+		awsec2.NewCfnTransitGatewayRouteTablePropagation(stack, jsii.Sprintf("TransitGatewayPropagation%d", index+1), &awsec2.CfnTransitGatewayRouteTablePropagationProps{
+			TransitGatewayAttachmentId: attachmentId,
+			TransitGatewayRouteTableId: jsii.String(routeTableId),
+		})
+	}
+
+	// Routes to a transit gateway fail until the VPC is attached
+	for index, destination := range destinations {
+		routes.add(fmt.Sprintf("TransitGatewayRoute%d", index+1), destination, awsec2.RouterType_TRANSIT_GATEWAY,
+			jsii.String(settings.TransitGatewayId), self.TransitGatewayAttachment)
+	}
+}
+
+// addPeerings creates the peering connections and their routes.
+func (self *NetworkStack) addPeerings(peerings []VpcPeeringSettings, routes *vpcRoutes) {
+	stack := self.Stack
+	if len(peerings) > 0 {
+		self.PeeringConnections = map[string]awsec2.CfnVPCPeeringConnection{}
+	}
+
+	for _, peering := range peerings {
+		if peering.Name == "" || peering.PeerVpcId == "" {
+			awscdk.Annotations_Of(stack).AddError(jsii.String("VPC peerings need a Name and PeerVpcId"))
+			continue
+		}
+		if _, ok := self.PeeringConnections[peering.Name]; ok {
+			awscdk.Annotations_Of(stack).AddError(jsii.Sprintf("VPC peering %s is declared more than once", peering.Name))
+			continue
+		}
+		if !validPeerAccount(stack, peering) {
+			continue
+		}
+		destination, ok := routes.destination(peering.PeerCidr, "VPC peering "+peering.Name)
+		if !ok {
+			continue
+		}
+
+		props := &awsec2.CfnVPCPeeringConnectionProps{
+			VpcId:     self.Vpc.VpcId(),
+			PeerVpcId: jsii.String(peering.PeerVpcId),
+		}
+		if peering.PeerRegion != "" {
+			props.PeerRegion = jsii.String(peering.PeerRegion)
+		}
+		if peering.PeerOwnerId != "" {
+			props.PeerOwnerId = jsii.String(peering.PeerOwnerId)
+		}
+		if peering.PeerRoleArn != "" {
+			props.PeerRoleArn = jsii.String(peering.PeerRoleArn)
+		}
+		connection := awsec2.NewCfnVPCPeeringConnection(stack, jsii.String(peering.Name+"Peering"), props)
+		awscdk.Tags_Of(connection).Add(jsii.String("Name"), jsii.String(peering.Name), nil)
+		self.PeeringConnections[peering.Name] = connection
+
+		routes.add(peering.Name+"PeeringRoute", destination, awsec2.RouterType_VPC_PEERING_CONNECTION, connection.Ref(), nil)
+	}
+}
+
+// validPeerAccount reports peerings with another account that the peer can't
+// accept. Environment agnostic stacks only know their account at deployment,
+// a missing PeerRoleArn is a warning for them.
+func validPeerAccount(stack awscdk.Stack, peering VpcPeeringSettings) bool {
+	accountUnknown := *awscdk.Token_IsUnresolved(stack.Account())
+	crossAccount := peering.PeerOwnerId != "" && (accountUnknown || peering.PeerOwnerId != *stack.Account())
+	switch {
+	case crossAccount && peering.PeerRoleArn == "" && accountUnknown:
+		awscdk.Annotations_Of(stack).AddWarning(jsii.Sprintf("VPC peering %s with account %s needs PeerRoleArn unless the stack is deployed to that account", peering.Name, peering.PeerOwnerId))
+		return true
+	case crossAccount && peering.PeerRoleArn == "":
+		awscdk.Annotations_Of(stack).AddError(jsii.Sprintf("VPC peering %s with account %s needs PeerRoleArn to accept the connection", peering.Name, peering.PeerOwnerId))
+		return false
+	case !crossAccount && peering.PeerRoleArn != "":
+		awscdk.Annotations_Of(stack).AddWarning(jsii.Sprintf("VPC peering %s is in the account of the stack, PeerRoleArn is not used", peering.Name))
+	}
+	if crossAccount && !*awscdk.Token_IsUnresolved(jsii.String(peering.PeerRoleArn)) {
+		parts := strings.Split(peering.PeerRoleArn, ":")
+		if len(parts) != 6 || parts[2] != "iam" || !strings.HasPrefix(parts[5], "role/") {
+			awscdk.Annotations_Of(stack).AddError(jsii.Sprintf("VPC peering %s: %q is not an IAM role ARN", peering.Name, peering.PeerRoleArn))
+			return false
+		}
+		if parts[4] != peering.PeerOwnerId {
+			awscdk.Annotations_Of(stack).AddError(jsii.Sprintf("VPC peering %s: PeerRoleArn is in account %s, not in the peer account %s", peering.Name, parts[4], peering.PeerOwnerId))
+			return false
+		}
+	}
+	return true
+}
+
+// vpcRoutes adds routes to the route tables of the private and isolated
+// subnets and reports destinations that overlap the VPC or that already have
+// a route.
+type vpcRoutes struct {
+	stack awscdk.Stack
+	vpc   awsec2.IVpc
+	// vpcCidr is empty for IPAM allocations, they are only known at deployment
+	vpcCidr string
+	// targets names the target of every destination for messages
+	targets map[string]string
+}
+
+func newVpcRoutes(stack awscdk.Stack, vpc awsec2.IVpc, vpcCidr string) *vpcRoutes {
+	return &vpcRoutes{stack: stack, vpc: vpc, vpcCidr: vpcCidr, targets: map[string]string{}}
+}
+
+// destination validates cidr and reserves it for target. It returns the
+// masked CIDR to pass to add.
+func (self *vpcRoutes) destination(cidr string, target string) (string, bool) {
+	destination, err := netip.ParsePrefix(cidr)
+	if err != nil || !destination.Addr().Is4() {
+		awscdk.Annotations_Of(self.stack).AddError(jsii.Sprintf("Route to %q through %s: not an IPv4 CIDR", cidr, target))
+		return "", false
+	}
+	destination = destination.Masked()
+	if vpcCidr, err := netip.ParsePrefix(self.vpcCidr); err == nil && vpcCidr.Overlaps(destination) {
+		awscdk.Annotations_Of(self.stack).AddError(jsii.Sprintf("Route to %s through %s overlaps the VPC CIDR %s", destination, target, self.vpcCidr))
+		return "", false
+	}
+	if other, ok := self.targets[destination.String()]; ok {
+		awscdk.Annotations_Of(self.stack).AddError(jsii.Sprintf("Route to %s through %s conflicts with the route through %s", destination, target, other))
+		return "", false
+	}
+	self.targets[destination.String()] = target
+	return destination.String(), true
+}
+
+// add routes the destination to the router in every private and isolated
+// subnet. The routes depend on dependency unless it is nil.
+func (self *vpcRoutes) add(id string, destination string, routerType awsec2.RouterType, routerId *string, dependency constructs.IDependable) {
+	subnets := append(append([]awsec2.ISubnet{}, *self.vpc.PrivateSubnets()...), *self.vpc.IsolatedSubnets()...)
+	for _, subnet := range subnets {
+		subnet.(awsec2.Subnet).AddRoute(jsii.String(id), &awsec2.AddRouteOptions{
+			RouterType:           routerType,
+			RouterId:             routerId,
+			DestinationCidrBlock: jsii.String(destination),
+		})
+		if dependency != nil {
+			subnet.Node().FindChild(jsii.String(id)).Node().AddDependency(dependency)
+		}
+	}
+}
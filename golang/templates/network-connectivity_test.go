@@ -0,0 +1,69 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestPeerAccount(t *testing.T) {
+	peering := VpcPeeringSettings{
+		Name:        "SharedServices",
+		PeerVpcId:   "vpc-0123456789abcdef0",
+		PeerCidr:    "10.200.0.0/16",
+		PeerOwnerId: "210987654321",
+	}
+	withRole := func(arn string) VpcPeeringSettings {
+		peering := peering
+		peering.PeerRoleArn = arn
+		return peering
+	}
+	tests := []struct {
+		name        string
+		environment *awscdk.Environment
+		peering     VpcPeeringSettings
+		peerings    int
+		wants       []string
+	}{
+		{"cross-account", testEnvironment, withRole("arn:aws:iam::210987654321:role/vpc-peering-accepter"), 1, nil},
+		{"cross-account without role", testEnvironment, peering, 0, []string{
+			"VPC peering SharedServices with account 210987654321 needs PeerRoleArn to accept the connection",
+		}},
+		{"role in another account", testEnvironment, withRole("arn:aws:iam::123456789012:role/vpc-peering-accepter"), 0, []string{
+			"VPC peering SharedServices: PeerRoleArn is in account 123456789012, not in the peer account 210987654321",
+		}},
+		{"environment agnostic without role", nil, peering, 1, nil},
+		{"environment agnostic with role", nil, withRole("arn:aws:iam::210987654321:role/vpc-peering-accepter"), 1, nil},
+		{"environment agnostic with invalid role", nil, withRole("vpc-peering-accepter"), 0, []string{
+			`VPC peering SharedServices: "vpc-peering-accepter" is not an IAM role ARN`,
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := NewNetworkStack(awscdk.NewApp(nil), "network", &NetworkStackProps{
+				StackProps:  awscdk.StackProps{Env: test.environment},
+				Environment: "dev",
+				Config:      testConfig,
+				Peerings:    []VpcPeeringSettings{test.peering},
+			})
+
+			assertAnnotationErrors(t, network.Stack, test.wants...)
+			template := assertions.Template_FromStack(network.Stack, nil)
+			template.ResourceCountIs(jsii.String("AWS::EC2::VPCPeeringConnection"), jsii.Number(test.peerings))
+		})
+	}
+
+	t.Run("environment agnostic warning", func(t *testing.T) {
+		network := NewNetworkStack(awscdk.NewApp(nil), "network", &NetworkStackProps{
+			Environment: "dev",
+			Config:      testConfig,
+			Peerings:    []VpcPeeringSettings{peering},
+		})
+
+		assertions.Annotations_FromStack(network.Stack).HasWarning(jsii.String("/network"),
+			assertions.Match_StringLikeRegexp(jsii.String("VPC peering SharedServices with account 210987654321 needs PeerRoleArn unless the stack is deployed to that account")))
+	})
+}
@@ -0,0 +1,249 @@
+package templates
+
+import (
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsglue"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
+	"github.com/aws/jsii-runtime-go"
+
+	"cdk-template/naming"
+)
+
+// FlowLogDestination selects where the flow logs of the VPC are delivered.
+type FlowLogDestination string
+
+const (
+	FlowLogsToCloudWatch FlowLogDestination = ""
+	// FlowLogsToS3 writes Parquet files with Hive compatible partitions and
+	// defines an Athena table over them.
+	FlowLogsToS3       FlowLogDestination = "s3"
+	FlowLogsToFirehose FlowLogDestination = "firehose"
+)
+
+// flowLogFieldTypes are the Athena column types of the flow log fields.
+var flowLogFieldTypes = map[string]string{
+	"version":             "int",
+	"account-id":          "string",
+	"interface-id":        "string",
+	"srcaddr":             "string",
+	"dstaddr":             "string",
+	"srcport":             "int",
+	"dstport":             "int",
+	"protocol":            "bigint",
+	"packets":             "bigint",
+	"bytes":               "bigint",
+	"start":               "bigint",
+	"end":                 "bigint",
+	"action":              "string",
+	"log-status":          "string",
+	"vpc-id":              "string",
+	"subnet-id":           "string",
+	"instance-id":         "string",
+	"tcp-flags":           "int",
+	"type":                "string",
+	"pkt-srcaddr":         "string",
+	"pkt-dstaddr":         "string",
+	"region":              "string",
+	"az-id":               "string",
+	"sublocation-type":    "string",
+	"sublocation-id":      "string",
+	"pkt-src-aws-service": "string",
+	"pkt-dst-aws-service": "string",
+	"flow-direction":      "string",
+	"traffic-path":        "int",
+}
+
+// DefaultFlowLogFields are the default fields followed by the ones showing
+// the original addresses behind NAT and load balancers.
+func DefaultFlowLogFields() []string {
+	return []string{
+		"version", "account-id", "interface-id", "srcaddr", "dstaddr", "srcport", "dstport",
+		"protocol", "packets", "bytes", "start", "end", "action", "log-status",
+		"vpc-id", "subnet-id", "az-id", "pkt-srcaddr", "pkt-dstaddr", "tcp-flags", "flow-direction", "traffic-path",
+	}
+}
+
+type FlowLogSettings struct {
+	Destination FlowLogDestination
+	// TrafficType defaults to ALL.
+	TrafficType awsec2.FlowLogTrafficType
+	// Fields default to DefaultFlowLogFields.
+	Fields []string
+	// Retention of the CloudWatch log group, defaults to one month.
+	Retention awslogs.RetentionDays
+	// Bucket defaults to a new bucket, KeyPrefix to none.
+	Bucket    awss3.IBucket
+	KeyPrefix string
+	// ExpirationDays of the objects in the new bucket, defaults to 90.
+	ExpirationDays int
+	// PerHourPartitions adds an hour partition below the day.
+	PerHourPartitions bool
+	// DeliveryStreamArn is required for Firehose.
+	DeliveryStreamArn string
+}
+
+// addFlowLogs logs the traffic of the VPC to the destination of settings.
+func (self *NetworkStack) addFlowLogs(settings *FlowLogSettings, names naming.Namer) {
+	if settings == nil {
+		return
+	}
+
+	fields := settings.Fields
+	if len(fields) == 0 {
+		fields = DefaultFlowLogFields()
+	}
+	var logFormat []awsec2.LogFormat
+	for _, field := range fields {
+		if _, ok := flowLogFieldTypes[field]; !ok {
+			awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("Unknown flow log field %q", field))
+			return
+		}
+		logFormat = append(logFormat, awsec2.LogFormat_Field(jsii.String(field)))
+	}
+
+	trafficType := settings.TrafficType
+	if trafficType == "" {
+		trafficType = awsec2.FlowLogTrafficType_ALL
+	}
+
+	var destination awsec2.FlowLogDestination
+	switch settings.Destination {
+	case FlowLogsToCloudWatch:
+		retention := settings.Retention
+		if retention == "" {
+			retention = awslogs.RetentionDays_ONE_MONTH
+		}
+		logGroup := awslogs.NewLogGroup(self.Stack, jsii.String("FlowLogGroup"), &awslogs.LogGroupProps{
+			Retention:     retention,
+			RemovalPolicy: awscdk.RemovalPolicy_DESTROY,
+		})
+		destination = awsec2.FlowLogDestination_ToCloudWatchLogs(logGroup, nil)
+	case FlowLogsToS3:
+		self.FlowLogBucket = settings.Bucket
+		if self.FlowLogBucket == nil {
+			expirationDays := settings.ExpirationDays
+			if expirationDays == 0 {
+				expirationDays = 90
+			}
+			self.FlowLogBucket = awss3.NewBucket(self.Stack, jsii.String("FlowLogBucket"), &awss3.BucketProps{
+				Encryption:        awss3.BucketEncryption_S3_MANAGED,
+				BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+				EnforceSSL:        jsii.Bool(true),
+				LifecycleRules: &[]*awss3.LifecycleRule{
+					{Expiration: awscdk.Duration_Days(jsii.Number(expirationDays))},
+				},
+			})
+		}
+		var keyPrefix *string
+		if settings.KeyPrefix != "" {
+			keyPrefix = jsii.String(strings.Trim(settings.KeyPrefix, "/"))
+		}
+		destination = awsec2.FlowLogDestination_ToS3(self.FlowLogBucket, keyPrefix, &awsec2.S3DestinationOptions{
+			FileFormat:               awsec2.FlowLogFileFormat_PARQUET,
+			HiveCompatiblePartitions: jsii.Bool(true),
+			PerHourPartition:         jsii.Bool(settings.PerHourPartitions),
+		})
+		self.FlowLogTable = self.flowLogTable(settings, fields, names)
+	case FlowLogsToFirehose:
+		if settings.DeliveryStreamArn == "" {
+			awscdk.Annotations_Of(self.Stack).AddError(jsii.String("Flow logs to Firehose need DeliveryStreamArn"))
+			return
+		}
+		destination = awsec2.FlowLogDestination_ToKinesisDataFirehoseDestination(jsii.String(settings.DeliveryStreamArn))
+	default:
+		awscdk.Annotations_Of(self.Stack).AddError(jsii.Sprintf("Unknown flow log destination %q", settings.Destination))
+		return
+	}
+
+	self.FlowLog = self.Vpc.AddFlowLog(jsii.String("FlowLog"), &awsec2.FlowLogOptions{
+		Destination: destination,
+		TrafficType: trafficType,
+		LogFormat:   &logFormat,
+	})
+}
+
+// flowLogTable defines the Athena table of the Parquet flow logs. Partition
+// projection derives the partitions from the dates, so there is no crawler to
+// run or partition to add.
+func (self *NetworkStack) flowLogTable(settings *FlowLogSettings, fields []string, names naming.Namer) awsglue.CfnTable {
+	var columns []interface{}
+	for _, field := range fields {
+		// Parquet flow logs name the columns with underscores
+		columns = append(columns, &awsglue.CfnTable_ColumnProperty{
+			Name: jsii.String(strings.ReplaceAll(field, "-", "_")),
+			Type: jsii.String(flowLogFieldTypes[field]),
+		})
+	}
+
+	partitions := []string{"year", "month", "day"}
+	if settings.PerHourPartitions {
+		partitions = append(partitions, "hour")
+	}
+
+	location := "s3://" + *self.FlowLogBucket.BucketName() + "/"
+	if settings.KeyPrefix != "" {
+		location += strings.Trim(settings.KeyPrefix, "/") + "/"
+	}
+	location += "AWSLogs/aws-account-id=" + *self.Account() + "/aws-service=vpcflowlogs/aws-region=" + *self.Region() + "/"
+
+	// Dates without flow logs, e.g. before the deployment, are empty partitions
+	parameters := map[string]interface{}{
+		"EXTERNAL":                "TRUE",
+		"classification":          "parquet",
+		"projection.enabled":      "true",
+		"projection.year.type":    "integer",
+		"projection.year.range":   "2024,2100",
+		"projection.month.type":   "integer",
+		"projection.month.range":  "1,12",
+		"projection.month.digits": "2",
+		"projection.day.type":     "integer",
+		"projection.day.range":    "1,31",
+		"projection.day.digits":   "2",
+	}
+	template := location
+	var partitionKeys []interface{}
+	for _, partition := range partitions {
+		template += partition + "=${" + partition + "}/"
+		partitionKeys = append(partitionKeys, &awsglue.CfnTable_ColumnProperty{
+			Name: jsii.String(partition),
+			Type: jsii.String("string"),
+		})
+	}
+	if settings.PerHourPartitions {
+		parameters["projection.hour.type"] = "integer"
+		parameters["projection.hour.range"] = "0,23"
+		parameters["projection.hour.digits"] = "2"
+	}
+	parameters["storage.location.template"] = template
+
+	database := awsglue.NewCfnDatabase(self.Stack, jsii.String("FlowLogDatabase"), &awsglue.CfnDatabaseProps{
+		CatalogId: self.Account(),
+		DatabaseInput: &awsglue.CfnDatabase_DatabaseInputProperty{
+			Name: jsii.String(names.Name(naming.GlueDatabase, "network")),
+		},
+	})
+
+	return awsglue.NewCfnTable(self.Stack, jsii.String("FlowLogTable"), &awsglue.CfnTableProps{
+		CatalogId:    self.Account(),
+		DatabaseName: database.Ref(),
+		TableInput: &awsglue.CfnTable_TableInputProperty{
+			Name:          jsii.String(names.Name(naming.GlueTable, "vpc-flow-logs")),
+			TableType:     jsii.String("EXTERNAL_TABLE"),
+			Parameters:    parameters,
+			PartitionKeys: &partitionKeys,
+			StorageDescriptor: &awsglue.CfnTable_StorageDescriptorProperty{
+				Columns:      &columns,
+				Location:     jsii.String(location),
+				InputFormat:  jsii.String("org.apache.hadoop.hive.ql.io.parquet.MapredParquetInputFormat"),
+				OutputFormat: jsii.String("org.apache.hadoop.hive.ql.io.parquet.MapredParquetOutputFormat"),
+				SerdeInfo: &awsglue.CfnTable_SerdeInfoProperty{
+					SerializationLibrary: jsii.String("org.apache.hadoop.hive.ql.io.parquet.serde.ParquetHiveSerDe"),
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,248 @@
+package templates
+
+import (
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsglue"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsssm"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"cdk-template/naming"
+)
+
+// NATStrategy selects how the private subnets reach the internet.
+type NATStrategy string
+
+const (
+	// NATGatewayPerAZ keeps the traffic of every AZ in its AZ.
+	NATGatewayPerAZ NATStrategy = ""
+	// SingleNATGateway is cheaper but the AZ of the gateway is a single point
+	// of failure.
+	SingleNATGateway NATStrategy = "single"
+	// NATInstance is a single t3.micro NAT instance, the cheapest option for
+	// dev. The image is looked up, so the stack needs an account and region.
+	NATInstance NATStrategy = "instance"
+	// NoNAT leaves out the private subnets, only public and isolated subnets
+	// are created. Dual-stack VPCs keep them, they still reach the internet
+	// over IPv6.
+	NoNAT NATStrategy = "none"
+)
+
+// SubnetMasks are the prefix lengths of the subnets of each tier, they
+// default to 24.
+type SubnetMasks struct {
+	Public   int
+	Private  int
+	Isolated int
+}
+
+type NetworkStackProps struct {
+	awscdk.StackProps
+	Environment string
+	Config      *Config
+	// Cidr defaults to 10.0.0.0/16 unless IpamPoolId is set.
+	Cidr string
+	// IpamPoolId allocates the CIDR from an IPv4 IPAM pool instead.
+	IpamPoolId string
+	// IpamNetmaskLength is the size of the allocation, defaults to 16.
+	IpamNetmaskLength int
+	// AZs defaults to 2.
+	AZs         int
+	SubnetMasks SubnetMasks
+	NAT         NATStrategy
+	// DualStack adds an Amazon provided IPv6 block, every subnet gets a /64 of
+	// it. The private subnets reach the internet over IPv6 through an
+	// egress-only internet gateway.
+	DualStack bool
+	// ParameterPrefix defaults to /<environment>/<project>/network.
+	ParameterPrefix string
+	// FlowLogs are off by default.
+	FlowLogs *FlowLogSettings
+	// NetworkACLs replace the default network ACL of their subnet groups,
+	// e.g. DatabaseSubnetsACL.
+	NetworkACLs []NetworkACLRuleSet
+	// TransitGateway attaches the VPC to an existing transit gateway.
+	TransitGateway *TransitGatewaySettings
+	// Peerings connect the VPC to other VPCs, e.g. a shared-services VPC.
+	Peerings []VpcPeeringSettings
+}
+
+// NetworkStack is a VPC with public, private and isolated subnets for the
+// other stacks to share. They either get Vpc passed in or read the SSM
+// parameters below ParameterPrefix:
+//
+//	vpc-id
+//	availability-zones
+//	<public|private|isolated>/subnet-ids
+//	<public|private|isolated>/route-table-ids
+type NetworkStack struct {
+	awscdk.Stack
+	Vpc             awsec2.Vpc
+	ParameterPrefix string
+	FlowLog         awsec2.FlowLog
+	// FlowLogBucket and FlowLogTable are set for flow logs to S3.
+	FlowLogBucket awss3.IBucket
+	FlowLogTable  awsglue.CfnTable
+	// NetworkACLs are the network ACLs by subnet group name.
+	NetworkACLs map[string]awsec2.NetworkAcl
+	// TransitGatewayAttachment is set with the TransitGateway prop.
+	TransitGatewayAttachment awsec2.CfnTransitGatewayAttachment
+	// PeeringConnections are the peering connections by name.
+	PeeringConnections map[string]awsec2.CfnVPCPeeringConnection
+}
+
+func NewNetworkStack(scope constructs.Construct, id string, props *NetworkStackProps) *NetworkStack {
+	self := &NetworkStack{
+		Stack: awscdk.NewStack(scope, &id, &props.StackProps),
+	}
+	stack := self.Stack
+	config := requireConfig(stack, props.Config)
+
+	azs := props.AZs
+	if azs == 0 {
+		azs = 2
+	}
+
+	var ipAddresses awsec2.IIpAddresses
+	// cidr stays empty for IPAM allocations, their block is only known at
+	// deployment
+	cidr := ""
+	if props.IpamPoolId != "" {
+		if props.Cidr != "" {
+			awscdk.Annotations_Of(stack).AddError(jsii.String("Either Cidr or IpamPoolId can be set"))
+		}
+		netmaskLength := props.IpamNetmaskLength
+		if netmaskLength == 0 {
+			netmaskLength = 16
+		}
+		ipAddresses = awsec2.IpAddresses_AwsIpamAllocation(&awsec2.AwsIpamProps{
+			Ipv4IpamPoolId:    jsii.String(props.IpamPoolId),
+			Ipv4NetmaskLength: jsii.Number(netmaskLength),
+		})
+	} else {
+		cidr = props.Cidr
+		if cidr == "" {
+			cidr = "10.0.0.0/16"
+		}
+		ipAddresses = awsec2.IpAddresses_Cidr(jsii.String(cidr))
+	}
+
+	vpcProps := awsec2.VpcProps{
+		IpAddresses:         ipAddresses,
+		MaxAzs:              jsii.Number(azs),
+		SubnetConfiguration: networkSubnets(props.SubnetMasks, props.NAT != NoNAT || props.DualStack),
+	}
+	if props.DualStack {
+		vpcProps.IpProtocol = awsec2.IpProtocol_DUAL_STACK
+		vpcProps.Ipv6Addresses = awsec2.Ipv6Addresses_AmazonProvided()
+	}
+	switch props.NAT {
+	case NATGatewayPerAZ:
+	case SingleNATGateway:
+		vpcProps.NatGateways = jsii.Number(1)
+	case NATInstance:
+		if *awscdk.Token_IsUnresolved(stack.Account()) || *awscdk.Token_IsUnresolved(stack.Region()) {
+			awscdk.Annotations_Of(stack).AddError(jsii.String("NAT instances need the account and region of the stack to look up their image"))
+			break
+		}
+		vpcProps.NatGateways = jsii.Number(1)
+		vpcProps.NatGatewayProvider = awsec2.NatProvider_Instance(&awsec2.NatInstanceProps{
+			InstanceType: awsec2.InstanceType_Of(awsec2.InstanceClass_BURSTABLE3, awsec2.InstanceSize_MICRO),
+		})
+	case NoNAT:
+		vpcProps.NatGateways = jsii.Number(0)
+	default:
+		awscdk.Annotations_Of(stack).AddError(jsii.Sprintf("Unknown NAT strategy %q", props.NAT))
+	}
+	self.Vpc = newVpc(stack, "Vpc", vpcProps)
+
+	self.ParameterPrefix = props.ParameterPrefix
+	if self.ParameterPrefix == "" {
+		self.ParameterPrefix = "/" + props.Environment + "/" + config.Project + "/network"
+	}
+	self.exportParameters()
+	self.addNetworkACLs(props.NetworkACLs, cidr)
+	routes := newVpcRoutes(stack, self.Vpc, cidr)
+	self.addTransitGateway(props.TransitGateway, routes)
+	self.addPeerings(props.Peerings, routes)
+	self.addFlowLogs(props.FlowLogs, naming.New(props.Environment, config.Project))
+
+	ApplyStandardTags(stack, config, props.Environment, "")
+
+	return self
+}
+
+func networkSubnets(masks SubnetMasks, private bool) *[]*awsec2.SubnetConfiguration {
+	mask := func(value int) *float64 {
+		if value == 0 {
+			value = 24
+		}
+		return jsii.Number(value)
+	}
+
+	subnets := []*awsec2.SubnetConfiguration{
+		{
+			CidrMask:   mask(masks.Public),
+			Name:       jsii.String("Public"),
+			SubnetType: awsec2.SubnetType_PUBLIC,
+		},
+	}
+	if private {
+		subnets = append(subnets, &awsec2.SubnetConfiguration{
+			CidrMask:   mask(masks.Private),
+			Name:       jsii.String("Private"),
+			SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS,
+		})
+	}
+	subnets = append(subnets, &awsec2.SubnetConfiguration{
+		CidrMask:   mask(masks.Isolated),
+		Name:       jsii.String("Isolated"),
+		SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED,
+	})
+	return &subnets
+}
+
+func (self *NetworkStack) exportParameters() {
+	awsssm.NewStringParameter(self.Stack, jsii.String("VpcIdParameter"), &awsssm.StringParameterProps{
+		ParameterName: jsii.String(self.ParameterPrefix + "/vpc-id"),
+		StringValue:   self.Vpc.VpcId(),
+	})
+	awsssm.NewStringListParameter(self.Stack, jsii.String("AvailabilityZonesParameter"), &awsssm.StringListParameterProps{
+		ParameterName:   jsii.String(self.ParameterPrefix + "/availability-zones"),
+		StringListValue: self.Vpc.AvailabilityZones(),
+	})
+
+	tiers := []struct {
+		name    string
+		subnets *[]awsec2.ISubnet
+	}{
+		{"Public", self.Vpc.PublicSubnets()},
+		{"Private", self.Vpc.PrivateSubnets()},
+		{"Isolated", self.Vpc.IsolatedSubnets()},
+	}
+	for _, tier := range tiers {
+		if len(*tier.subnets) == 0 {
+			continue
+		}
+
+		var subnetIds, routeTableIds []*string
+		for _, subnet := range *tier.subnets {
+			subnetIds = append(subnetIds, subnet.SubnetId())
+			routeTableIds = append(routeTableIds, subnet.RouteTable().RouteTableId())
+		}
+
+		path := self.ParameterPrefix + "/" + strings.ToLower(tier.name)
+		awsssm.NewStringListParameter(self.Stack, jsii.String(tier.name+"SubnetIdsParameter"), &awsssm.StringListParameterProps{
+			ParameterName:   jsii.String(path + "/subnet-ids"),
+			StringListValue: &subnetIds,
+		})
+		awsssm.NewStringListParameter(self.Stack, jsii.String(tier.name+"RouteTableIdsParameter"), &awsssm.StringListParameterProps{
+			ParameterName:   jsii.String(path + "/route-table-ids"),
+			StringListValue: &routeTableIds,
+		})
+	}
+}
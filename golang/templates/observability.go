@@ -0,0 +1,280 @@
+package templates
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatch"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatchactions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssnssubscriptions"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"cdk-template/naming"
+)
+
+// AlarmThresholds are evaluated over 5 minute periods. Nil values fall back
+// to the defaults for the environment, a zero threshold alarms on any value
+// above zero.
+type AlarmThresholds struct {
+	API4XXRatePercent       *float64
+	API5XXRatePercent       *float64
+	APILatencyP99Millis     *float64
+	LambdaErrors            *float64
+	LambdaThrottles         *float64
+	LambdaDurationP99Millis *float64
+	WAFBlockedRequests      *float64
+	DeadLetterMessages      *float64
+}
+
+// APIMetrics is implemented by both awsapigateway.RestApi and awsapigatewayv2.HttpApi.
+type APIMetrics interface {
+	MetricClientError(props *awscloudwatch.MetricOptions) awscloudwatch.Metric
+	MetricServerError(props *awscloudwatch.MetricOptions) awscloudwatch.Metric
+	MetricCount(props *awscloudwatch.MetricOptions) awscloudwatch.Metric
+	MetricLatency(props *awscloudwatch.MetricOptions) awscloudwatch.Metric
+}
+
+type ObservabilityProps struct {
+	Environment  string
+	Project      string
+	IsProduction bool
+	// Name used as prefix for the alarm topic, alarms and dashboard.
+	Name          string
+	AlarmEmails   []string
+	AlarmWebhooks []string
+	// Thresholds overrides the defaults, keyed by environment.
+	Thresholds map[string]AlarmThresholds
+
+	Api              APIMetrics
+	Functions        []awslambda.IFunction
+	WebACLMetricName string
+	DeadLetterTopic  awssns.ITopic
+}
+
+type Observability struct {
+	constructs.Construct
+	AlarmTopic awssns.ITopic
+	Dashboard  awscloudwatch.Dashboard
+	Alarms     []awscloudwatch.Alarm
+}
+
+func defaultAlarmThresholds(isProduction bool) AlarmThresholds {
+	if isProduction {
+		return AlarmThresholds{
+			API4XXRatePercent:       jsii.Number(5),
+			API5XXRatePercent:       jsii.Number(1),
+			APILatencyP99Millis:     jsii.Number(2000),
+			LambdaErrors:            jsii.Number(1),
+			LambdaThrottles:         jsii.Number(1),
+			LambdaDurationP99Millis: jsii.Number(8000),
+			WAFBlockedRequests:      jsii.Number(500),
+			DeadLetterMessages:      jsii.Number(1),
+		}
+	}
+
+	return AlarmThresholds{
+		API4XXRatePercent:       jsii.Number(20),
+		API5XXRatePercent:       jsii.Number(5),
+		APILatencyP99Millis:     jsii.Number(5000),
+		LambdaErrors:            jsii.Number(5),
+		LambdaThrottles:         jsii.Number(5),
+		LambdaDurationP99Millis: jsii.Number(9000),
+		WAFBlockedRequests:      jsii.Number(1000),
+		DeadLetterMessages:      jsii.Number(1),
+	}
+}
+
+// withOverrides returns the thresholds with every override that is set applied.
+func (thresholds AlarmThresholds) withOverrides(overrides AlarmThresholds) AlarmThresholds {
+	pick := func(value, override *float64) *float64 {
+		if override != nil {
+			return override
+		}
+		return value
+	}
+
+	return AlarmThresholds{
+		API4XXRatePercent:       pick(thresholds.API4XXRatePercent, overrides.API4XXRatePercent),
+		API5XXRatePercent:       pick(thresholds.API5XXRatePercent, overrides.API5XXRatePercent),
+		APILatencyP99Millis:     pick(thresholds.APILatencyP99Millis, overrides.APILatencyP99Millis),
+		LambdaErrors:            pick(thresholds.LambdaErrors, overrides.LambdaErrors),
+		LambdaThrottles:         pick(thresholds.LambdaThrottles, overrides.LambdaThrottles),
+		LambdaDurationP99Millis: pick(thresholds.LambdaDurationP99Millis, overrides.LambdaDurationP99Millis),
+		WAFBlockedRequests:      pick(thresholds.WAFBlockedRequests, overrides.WAFBlockedRequests),
+		DeadLetterMessages:      pick(thresholds.DeadLetterMessages, overrides.DeadLetterMessages),
+	}
+}
+
+// NewObservability creates the alarm topic, the alarms for whatever resources
+// are set in props and a dashboard showing them.
+func NewObservability(scope constructs.Construct, id string, props *ObservabilityProps) *Observability {
+	self := &Observability{
+		Construct: constructs.NewConstruct(scope, &id),
+	}
+
+	// Topic and dashboard names only allow alphanumerics, dashes and underscores
+	names := naming.New(props.Environment, props.Project)
+	thresholds := defaultAlarmThresholds(props.IsProduction).withOverrides(props.Thresholds[props.Environment])
+	period := awscdk.Duration_Minutes(jsii.Number(5))
+
+	alarmTopic := awssns.NewTopic(self.Construct, jsii.String("AlarmTopic"), &awssns.TopicProps{
+		DisplayName: jsii.String(props.Environment + " " + props.Name + " alarms"),
+		TopicName:   jsii.String(names.Name(naming.SNSTopic, props.Name, "alarms")),
+	})
+	for _, email := range props.AlarmEmails {
+		alarmTopic.AddSubscription(awssnssubscriptions.NewEmailSubscription(jsii.String(email), nil))
+	}
+	for _, webhook := range props.AlarmWebhooks {
+		alarmTopic.AddSubscription(awssnssubscriptions.NewUrlSubscription(jsii.String(webhook), &awssnssubscriptions.UrlSubscriptionProps{
+			Protocol: awssns.SubscriptionProtocol_HTTPS,
+		}))
+	}
+	self.AlarmTopic = alarmTopic
+
+	var apiWidgets, lambdaWidgets, securityWidgets []awscloudwatch.IWidget
+
+	if props.Api != nil {
+		requests := props.Api.MetricCount(&awscloudwatch.MetricOptions{Period: period, Statistic: jsii.String("Sum")})
+		clientErrors := props.Api.MetricClientError(&awscloudwatch.MetricOptions{Period: period, Statistic: jsii.String("Sum")})
+		serverErrors := props.Api.MetricServerError(&awscloudwatch.MetricOptions{Period: period, Statistic: jsii.String("Sum")})
+		latency := props.Api.MetricLatency(&awscloudwatch.MetricOptions{Period: period, Statistic: jsii.String("p99")})
+
+		clientErrorRate := awscloudwatch.NewMathExpression(&awscloudwatch.MathExpressionProps{
+			Expression:   jsii.String("IF(requests > 0, 100 * clientErrors / requests, 0)"),
+			UsingMetrics: &map[string]awscloudwatch.IMetric{"requests": requests, "clientErrors": clientErrors},
+			Label:        jsii.String("4XX rate (%)"),
+			Period:       period,
+		})
+		serverErrorRate := awscloudwatch.NewMathExpression(&awscloudwatch.MathExpressionProps{
+			Expression:   jsii.String("IF(requests > 0, 100 * serverErrors / requests, 0)"),
+			UsingMetrics: &map[string]awscloudwatch.IMetric{"requests": requests, "serverErrors": serverErrors},
+			Label:        jsii.String("5XX rate (%)"),
+			Period:       period,
+		})
+
+		self.addAlarm(props, "Api4XXRate", clientErrorRate, thresholds.API4XXRatePercent, "API 4XX rate", "%")
+		self.addAlarm(props, "Api5XXRate", serverErrorRate, thresholds.API5XXRatePercent, "API 5XX rate", "%")
+		self.addAlarm(props, "ApiLatencyP99", latency, thresholds.APILatencyP99Millis, "API p99 latency", "ms")
+
+		apiWidgets = append(apiWidgets,
+			awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+				Title: jsii.String("API requests"),
+				Left:  &[]awscloudwatch.IMetric{requests},
+				Width: jsii.Number(8),
+			}),
+			awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+				Title:           jsii.String("API error rates"),
+				Left:            &[]awscloudwatch.IMetric{clientErrorRate, serverErrorRate},
+				LeftAnnotations: &[]*awscloudwatch.HorizontalAnnotation{{Value: thresholds.API5XXRatePercent, Label: jsii.String("5XX threshold")}},
+				Width:           jsii.Number(8),
+			}),
+			awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+				Title:           jsii.String("API p99 latency"),
+				Left:            &[]awscloudwatch.IMetric{latency},
+				LeftAnnotations: &[]*awscloudwatch.HorizontalAnnotation{{Value: thresholds.APILatencyP99Millis, Label: jsii.String("threshold")}},
+				Width:           jsii.Number(8),
+			}),
+		)
+	}
+
+	for i, function := range props.Functions {
+		suffix := fmt.Sprintf("%d", i+1)
+		errors := function.MetricErrors(&awscloudwatch.MetricOptions{Period: period, Statistic: jsii.String("Sum")})
+		throttles := function.MetricThrottles(&awscloudwatch.MetricOptions{Period: period, Statistic: jsii.String("Sum")})
+		duration := function.MetricDuration(&awscloudwatch.MetricOptions{Period: period, Statistic: jsii.String("p99")})
+
+		self.addAlarm(props, "LambdaErrors"+suffix, errors, thresholds.LambdaErrors, "Lambda errors", "")
+		self.addAlarm(props, "LambdaThrottles"+suffix, throttles, thresholds.LambdaThrottles, "Lambda throttles", "")
+		self.addAlarm(props, "LambdaDurationP99"+suffix, duration, thresholds.LambdaDurationP99Millis, "Lambda p99 duration", "ms")
+
+		lambdaWidgets = append(lambdaWidgets, awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+			Title: jsii.String("Lambda " + suffix + " errors, throttles and p99 duration"),
+			Left:  &[]awscloudwatch.IMetric{errors, throttles},
+			Right: &[]awscloudwatch.IMetric{duration},
+			Width: jsii.Number(8),
+		}))
+	}
+
+	if props.WebACLMetricName != "" {
+		blocked := awscloudwatch.NewMetric(&awscloudwatch.MetricProps{
+			Namespace:  jsii.String("AWS/WAFV2"),
+			MetricName: jsii.String("BlockedRequests"),
+			DimensionsMap: &map[string]*string{
+				"WebACL": jsii.String(props.WebACLMetricName),
+				"Region": awscdk.Aws_REGION(),
+				"Rule":   jsii.String("ALL"),
+			},
+			Period:    period,
+			Statistic: jsii.String("Sum"),
+		})
+		self.addAlarm(props, "WafBlockedRequests", blocked, thresholds.WAFBlockedRequests, "WAF blocked requests", "")
+
+		securityWidgets = append(securityWidgets, awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+			Title: jsii.String("WAF blocked requests"),
+			Left:  &[]awscloudwatch.IMetric{blocked},
+			Width: jsii.Number(8),
+		}))
+	}
+
+	if props.DeadLetterTopic != nil {
+		deadLetters := props.DeadLetterTopic.MetricNumberOfMessagesPublished(&awscloudwatch.MetricOptions{Period: period, Statistic: jsii.String("Sum")})
+		self.addAlarm(props, "DeadLetterMessages", deadLetters, thresholds.DeadLetterMessages, "Dead letter messages", "")
+
+		securityWidgets = append(securityWidgets, awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+			Title: jsii.String("Dead letter messages"),
+			Left:  &[]awscloudwatch.IMetric{deadLetters},
+			Width: jsii.Number(8),
+		}))
+	}
+
+	var alarms []awscloudwatch.IAlarm
+	for _, alarm := range self.Alarms {
+		alarms = append(alarms, alarm)
+	}
+
+	self.Dashboard = awscloudwatch.NewDashboard(self.Construct, jsii.String("Dashboard"), &awscloudwatch.DashboardProps{
+		DashboardName: jsii.String(names.Name(naming.CloudWatchDashboard, props.Name)),
+		Widgets: &[]*[]awscloudwatch.IWidget{
+			{awscloudwatch.NewAlarmStatusWidget(&awscloudwatch.AlarmStatusWidgetProps{
+				Title:  jsii.String("Alarms"),
+				Alarms: &alarms,
+				Width:  jsii.Number(24),
+			})},
+			&apiWidgets,
+			&lambdaWidgets,
+			&securityWidgets,
+		},
+	})
+
+	return self
+}
+
+// addAlarm alarms when metric reaches threshold in one 5 minute period and
+// notifies the alarm topic on both ALARM and OK. A zero threshold alarms when
+// the metric is above zero, reaching it would always alarm.
+func (self *Observability) addAlarm(props *ObservabilityProps, id string, metric awscloudwatch.IMetric, threshold *float64, subject string, unit string) {
+	operator := awscloudwatch.ComparisonOperator_GREATER_THAN_OR_EQUAL_TO_THRESHOLD
+	description := fmt.Sprintf("%s at or above %v%s", subject, *threshold, unit)
+	if *threshold == 0 {
+		operator = awscloudwatch.ComparisonOperator_GREATER_THAN_THRESHOLD
+		description = fmt.Sprintf("%s above 0%s", subject, unit)
+	}
+
+	alarm := awscloudwatch.NewAlarm(self.Construct, jsii.String(id), &awscloudwatch.AlarmProps{
+		Metric:             metric,
+		Threshold:          threshold,
+		EvaluationPeriods:  jsii.Number(1),
+		ComparisonOperator: operator,
+		TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+		AlarmDescription:   jsii.String(props.Environment + " " + props.Name + ": " + description),
+	})
+
+	action := awscloudwatchactions.NewSnsAction(self.AlarmTopic)
+	alarm.AddAlarmAction(action)
+	alarm.AddOkAction(action)
+
+	self.Alarms = append(self.Alarms, alarm)
+}
@@ -0,0 +1,30 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestObservabilityThresholdOverrides(t *testing.T) {
+	stack := awscdk.NewStack(awscdk.NewApp(nil), jsii.String("observability"), &awscdk.StackProps{Env: testEnvironment})
+	NewObservability(stack, "Observability", &ObservabilityProps{
+		Environment: "dev",
+		Project:     "Template",
+		Name:        "api",
+		Thresholds: map[string]AlarmThresholds{
+			"dev": {DeadLetterMessages: jsii.Number(0)},
+		},
+		DeadLetterTopic: awssns.NewTopic(stack, jsii.String("DeadLetters"), nil),
+	})
+
+	template := assertions.Template_FromStack(stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::CloudWatch::Alarm"), map[string]interface{}{
+		"Threshold":          0,
+		"ComparisonOperator": "GreaterThanThreshold",
+		"AlarmDescription":   "dev api: Dead letter messages above 0",
+	})
+}
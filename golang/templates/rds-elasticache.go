@@ -5,68 +5,96 @@ import (
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awselasticache"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsrds"
+	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
+
+	"cdk-template/naming"
 )
 
-func CreateElastiCache() {
-	// Create the app properly
-	app := awscdk.NewApp(nil)
+type RDSElastiCacheProps struct {
+	Environment  string
+	IsProduction bool
+	Config       *Config
+	// ExistingVpcProps default to a new VPC with public, private and isolated
+	// subnets in two AZs. The database is placed in the isolated subnets.
+	ExistingVpcProps
+	// CacheNodeType defaults to cache.t3.micro.
+	CacheNodeType string
+}
 
-	// pass it as parameter or use this function as main
-	stack := awscdk.NewStack(app, jsii.String("StickySessionStack"), &awscdk.StackProps{
-		Env: &awscdk.Environment{
-			Account: jsii.String("YOUR_ACCOUNT_ID"),
-			Region:  jsii.String("YOUR_REGION"),
-		},
-	})
+// RDSElastiCache is a PostgreSQL instance with a Redis cluster in front of it.
+type RDSElastiCache struct {
+	constructs.Construct
+	Vpc                awsec2.IVpc
+	CacheCluster       awselasticache.CfnCacheCluster
+	CacheSecurityGroup awsec2.SecurityGroup
+	Database           awsrds.DatabaseInstance
+}
 
-	// Create a VPC
-	vpc := awsec2.NewVpc(stack, jsii.String("MyVPC"), &awsec2.VpcProps{
-		MaxAzs: jsii.Number(2),
-		SubnetConfiguration: &[]*awsec2.SubnetConfiguration{
-			{
-				CidrMask:   jsii.Number(24),
-				Name:       jsii.String("Public"),
-				SubnetType: awsec2.SubnetType_PUBLIC,
-			},
-			{
-				CidrMask:   jsii.Number(24),
-				Name:       jsii.String("Private"),
-				SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS,
+func NewRDSElastiCache(scope constructs.Construct, id string, props *RDSElastiCacheProps) *RDSElastiCache {
+	self := &RDSElastiCache{
+		Construct: constructs.NewConstruct(scope, &id),
+	}
+	config := requireConfig(self.Construct, props.Config)
+	names := naming.New(props.Environment, config.Project)
+
+	self.Vpc = props.existingVpc(self.Construct)
+	if self.Vpc == nil {
+		self.Vpc = newVpc(self.Construct, "MyVPC", awsec2.VpcProps{
+			MaxAzs: jsii.Number(2),
+			SubnetConfiguration: &[]*awsec2.SubnetConfiguration{
+				{
+					CidrMask:   jsii.Number(24),
+					Name:       jsii.String("Public"),
+					SubnetType: awsec2.SubnetType_PUBLIC,
+				},
+				{
+					CidrMask:   jsii.Number(24),
+					Name:       jsii.String("Private"),
+					SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS,
+				},
+				{
+					CidrMask:   jsii.Number(24),
+					Name:       jsii.String("Isolated"),
+					SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED,
+				},
 			},
-		},
-	})
+		})
+	}
 
-	// Create a security group for Elasticache
-	cacheSecurityGroup := awsec2.NewSecurityGroup(stack, jsii.String("CacheSecurityGroup"), &awsec2.SecurityGroupProps{
-		Vpc:         vpc,
-		Description: jsii.String("Security group for Elasticache"),
-	})
-	cacheSecurityGroup.AddIngressRule(awsec2.Peer_Ipv4(vpc.VpcCidrBlock()), awsec2.Port_Tcp(jsii.Number(6379)), jsii.String("Allow inbound from VPC"), nil)
+	isolatedSubnets := &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED}
+	validPrivate := validSubnetSelection(self.Construct, self.Vpc, &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS}, "Redis cluster")
+	validIsolated := validSubnetSelection(self.Construct, self.Vpc, isolatedSubnets, "Database")
+	if !validPrivate || !validIsolated {
+		return self
+	}
 
-	// Create the Elasticache cluster
-	cacheCluster := awselasticache.NewCfnCacheCluster(stack, jsii.String("MyCacheCluster"), &awselasticache.CfnCacheClusterProps{
-		CacheNodeType:       jsii.String("cache.t2.micro"),
-		Engine:              jsii.String("redis"),
-		NumCacheNodes:       jsii.Number(1),
-		VpcSecurityGroupIds: jsii.Strings(*cacheSecurityGroup.SecurityGroupId()),
-		ClusterName:         jsii.String("my-cache-cluster"),
-	})
+	self.CacheSecurityGroup, self.CacheCluster = newRedisCluster(self.Construct, self.Vpc, names, id, props.CacheNodeType)
+
+	removalPolicy := awscdk.RemovalPolicy_DESTROY
+	if props.IsProduction {
+		removalPolicy = awscdk.RemovalPolicy_SNAPSHOT
+	}
 
 	// Create RDS Instance
-	seconds := float64(64)
-	awsrds.NewDatabaseInstance(stack, jsii.String("MyRDS"), &awsrds.DatabaseInstanceProps{
+	seconds := float64(60)
+	self.Database = awsrds.NewDatabaseInstance(self.Construct, jsii.String("MyRDS"), &awsrds.DatabaseInstanceProps{
 		Engine:                    awsrds.DatabaseInstanceEngine_POSTGRES(),
-		InstanceType:              awsec2.InstanceType_Of(awsec2.InstanceClass_BURSTABLE2, awsec2.InstanceSize_MICRO),
-		Vpc:                       vpc,
-		VpcSubnets:                &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED},
+		InstanceType:              awsec2.InstanceType_Of(awsec2.InstanceClass_BURSTABLE3, awsec2.InstanceSize_MICRO),
+		Vpc:                       self.Vpc,
+		VpcSubnets:                isolatedSubnets,
+		InstanceIdentifier:        jsii.String(names.Name(naming.RDSInstance, id, "postgres")),
 		AllowMajorVersionUpgrade:  jsii.Bool(true),
 		AutoMinorVersionUpgrade:   jsii.Bool(true),
-		RemovalPolicy:             awscdk.RemovalPolicy_DESTROY,
+		RemovalPolicy:             removalPolicy,
+		DeletionProtection:        jsii.Bool(props.IsProduction),
 		StorageEncrypted:          jsii.Bool(true),
 		MonitoringInterval:        awscdk.Duration_Seconds(&seconds),
 		EnablePerformanceInsights: jsii.Bool(true),
 		PubliclyAccessible:        jsii.Bool(false),
 	})
 
+	ApplyStandardTags(self.Construct, config, props.Environment, "")
+
+	return self
 }
@@ -1,173 +1,209 @@
 package templates
 
 import (
+	"fmt"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
 )
 
-func CNamePolicies() {
-	app := awscdk.NewApp(nil)
-
-	stack := awscdk.NewStack(app, jsii.String("ABTestingStack"), &awscdk.StackProps{
-		Env: &awscdk.Environment{
-			Account: jsii.String("YOUR_ACCOUNT_ID"),
-			Region:  jsii.String("YOUR_REGION"),
-		},
-	})
-
-	// Create a hosted zone
-	hostedZone := awsroute53.NewHostedZone(stack, jsii.String("MyHostedZone"), &awsroute53.HostedZoneProps{
-		ZoneName: jsii.String("example.com"), // Replace with your domain name
-	})
+// HostedZoneProps selects the zone of the routing constructs. Zone takes an
+// existing or imported zone, otherwise a public zone named ZoneName is created.
+type HostedZoneProps struct {
+	Zone     awsroute53.IHostedZone
+	ZoneName string
+}
 
-	// Create a CNAME record
-	awsroute53.NewCnameRecord(stack, jsii.String("MyCnameRecord"), &awsroute53.CnameRecordProps{
-		Zone:       hostedZone,
-		RecordName: jsii.String("sub.example.com"), // Subdomain
-		Weight:     jsii.Number(50),
-		DomainName: jsii.String("example.com"),
-		Comment:    jsii.String("example cname record"),
+func (props *HostedZoneProps) hostedZone(scope constructs.Construct) awsroute53.IHostedZone {
+	if props.Zone != nil {
+		return props.Zone
+	}
+	if props.ZoneName == "" {
+		awscdk.Annotations_Of(scope).AddError(jsii.String("Either Zone or ZoneName is required"))
+	}
+	return awsroute53.NewHostedZone(scope, jsii.String("HostedZone"), &awsroute53.HostedZoneProps{
+		ZoneName: jsii.String(props.ZoneName),
 	})
+}
 
-	// Create a CNAME record
-	awsroute53.NewCnameRecord(stack, jsii.String("MyCnameRecord"), &awsroute53.CnameRecordProps{
-		Zone:       hostedZone,
-		RecordName: jsii.String("sub.example.com"), // Subdomain
-		Weight:     jsii.Number(50),
-		DomainName: jsii.String("example.com"),
-		Comment:    jsii.String("example cname record"),
-		Region:     jsii.String("eu-west-1"),
-	})
+type WeightedTarget struct {
+	DomainName string
+	Weight     float64
 }
 
-func ARecord() {
-	app := awscdk.NewApp(nil)
+type CNamePoliciesProps struct {
+	HostedZoneProps
+	// RecordName is the subdomain, e.g. sub.example.com.
+	RecordName string
+	Targets    []WeightedTarget
+}
 
-	stack := awscdk.NewStack(app, jsii.String("ABTestingStack"), &awscdk.StackProps{
-		Env: &awscdk.Environment{
-			Account: jsii.String("YOUR_ACCOUNT_ID"),
-			Region:  jsii.String("YOUR_REGION"),
-		},
-	})
+type CNamePolicies struct {
+	constructs.Construct
+	Zone    awsroute53.IHostedZone
+	Records []awsroute53.CnameRecord
+}
 
-	// Create a Route 53 hosted zone
-	hostedZone := awsroute53.NewHostedZone(stack, jsii.String("MyHostedZone"), &awsroute53.HostedZoneProps{
-		ZoneName: jsii.String("example.com"),
-	})
+// NewCNamePolicies splits the traffic of RecordName between the targets in
+// proportion to their weights, e.g. for A/B testing.
+func NewCNamePolicies(scope constructs.Construct, id string, props *CNamePoliciesProps) *CNamePolicies {
+	self := &CNamePolicies{
+		Construct: constructs.NewConstruct(scope, &id),
+	}
+	self.Zone = props.hostedZone(self.Construct)
+
+	for index, target := range props.Targets {
+		record := awsroute53.NewCnameRecord(self.Construct, jsii.Sprintf("CnameRecord%d", index+1), &awsroute53.CnameRecordProps{
+			Zone:       self.Zone,
+			RecordName: jsii.String(props.RecordName),
+			Weight:     jsii.Number(target.Weight),
+			DomainName: jsii.String(target.DomainName),
+			Comment:    jsii.String(fmt.Sprintf("Weighted %s record for %s", props.RecordName, target.DomainName)),
+		})
+		self.Records = append(self.Records, record)
+	}
+
+	return self
+}
 
-	// Create A record
-	awsroute53.NewARecord(stack, jsii.String("ARecord"), &awsroute53.ARecordProps{
-		Zone:       hostedZone,
-		RecordName: jsii.String("www"),
-		Target:     awsroute53.RecordTarget_FromIpAddresses(jsii.String("1.2.3.4"), jsii.String("5.6.7.8")),
-	})
+type ARecordProps struct {
+	HostedZoneProps
+	// RecordName is relative to the zone, e.g. www.
+	RecordName  string
+	IPAddresses []string
 }
 
-func GeoLocationRouting() {
-	app := awscdk.NewApp(nil)
+type ARecord struct {
+	constructs.Construct
+	Zone   awsroute53.IHostedZone
+	Record awsroute53.ARecord
+}
 
-	stack := awscdk.NewStack(app, jsii.String("ABTestingStack"), &awscdk.StackProps{
-		Env: &awscdk.Environment{
-			Account: jsii.String("YOUR_ACCOUNT_ID"),
-			Region:  jsii.String("YOUR_REGION"),
-		},
-	})
+func NewARecord(scope constructs.Construct, id string, props *ARecordProps) *ARecord {
+	self := &ARecord{
+		Construct: constructs.NewConstruct(scope, &id),
+	}
+	self.Zone = props.hostedZone(self.Construct)
 
-	// Create a hosted zone
-	hostedZone := awsroute53.NewHostedZone(stack, jsii.String("MyHostedZone"), &awsroute53.HostedZoneProps{
-		ZoneName: jsii.String("example.com"), // Replace with your domain name
+	self.Record = awsroute53.NewARecord(self.Construct, jsii.String("ARecord"), &awsroute53.ARecordProps{
+		Zone:       self.Zone,
+		RecordName: jsii.String(props.RecordName),
+		Target:     awsroute53.RecordTarget_FromIpAddresses(*jsii.Strings(props.IPAddresses...)...),
 	})
 
-	// Create a CNAME record
-	awsroute53.NewCnameRecord(stack, jsii.String("MyCnameRecord"), &awsroute53.CnameRecordProps{
-		Zone:        hostedZone,
-		RecordName:  jsii.String("sub.example.com"), // Subdomain
-		GeoLocation: awsroute53.GeoLocation_Continent(awsroute53.Continent_EUROPE),
-		DomainName:  jsii.String("example.com"),
-		Comment:     jsii.String("example cname record"),
-		Region:      jsii.String("eu-west-1"),
-	})
+	return self
+}
 
-	// continent
-	// continent
-	awsroute53.NewARecord(stack, jsii.String("ARecordGeoLocationContinent"), &awsroute53.ARecordProps{
-		Zone:        hostedZone,
-		Target:      awsroute53.RecordTarget_FromIpAddresses(jsii.String("1.2.3.0"), jsii.String("5.6.7.0")),
-		GeoLocation: awsroute53.GeoLocation_Continent(awsroute53.Continent_EUROPE),
-	})
+type GeoLocationTarget struct {
+	// Location is e.g. awsroute53.GeoLocation_Continent(awsroute53.Continent_EUROPE),
+	// GeoLocation_Country(jsii.String("DE")) or GeoLocation_Subdivision(jsii.String("WA"), jsii.String("US")).
+	Location    awsroute53.GeoLocation
+	IPAddresses []string
+}
 
-	// country
-	// country
-	awsroute53.NewARecord(stack, jsii.String("ARecordGeoLocationCountry"), &awsroute53.ARecordProps{
-		Zone:        hostedZone,
-		Target:      awsroute53.RecordTarget_FromIpAddresses(jsii.String("1.2.3.1"), jsii.String("5.6.7.1")),
-		GeoLocation: awsroute53.GeoLocation_Country(jsii.String("DE")),
-	})
+type GeoLocationRoutingProps struct {
+	HostedZoneProps
+	RecordName string
+	Locations  []GeoLocationTarget
+	// DefaultIPAddresses answer the requests matching none of the locations.
+	DefaultIPAddresses []string
+}
 
-	// subdivision
-	// subdivision
-	awsroute53.NewARecord(stack, jsii.String("ARecordGeoLocationSubDividion"), &awsroute53.ARecordProps{
-		Zone:        hostedZone,
-		Target:      awsroute53.RecordTarget_FromIpAddresses(jsii.String("1.2.3.2"), jsii.String("5.6.7.2")),
-		GeoLocation: awsroute53.GeoLocation_Subdivision(jsii.String("Subdivision Code"), jsii.String("WA")),
-	})
+type GeoLocationRouting struct {
+	constructs.Construct
+	Zone    awsroute53.IHostedZone
+	Records []awsroute53.ARecord
+}
 
-	// default (wildcard record if no specific record is found)
-	// default (wildcard record if no specific record is found)
-	awsroute53.NewARecord(stack, jsii.String("ARecordGeoLocationDefault"), &awsroute53.ARecordProps{
-		Zone:        hostedZone,
-		Target:      awsroute53.RecordTarget_FromIpAddresses(jsii.String("1.2.3.3"), jsii.String("5.6.7.3")),
-		GeoLocation: awsroute53.GeoLocation_Default(),
-	})
+func NewGeoLocationRouting(scope constructs.Construct, id string, props *GeoLocationRoutingProps) *GeoLocationRouting {
+	self := &GeoLocationRouting{
+		Construct: constructs.NewConstruct(scope, &id),
+	}
+	self.Zone = props.hostedZone(self.Construct)
+
+	targets := props.Locations
+	if len(props.DefaultIPAddresses) > 0 {
+		// default (wildcard record if no specific record is found)
+		targets = append(targets, GeoLocationTarget{Location: awsroute53.GeoLocation_Default(), IPAddresses: props.DefaultIPAddresses})
+	}
+
+	for index, target := range targets {
+		record := awsroute53.NewARecord(self.Construct, jsii.Sprintf("ARecordGeoLocation%d", index+1), &awsroute53.ARecordProps{
+			Zone:        self.Zone,
+			RecordName:  jsii.String(props.RecordName),
+			Target:      awsroute53.RecordTarget_FromIpAddresses(*jsii.Strings(target.IPAddresses...)...),
+			GeoLocation: target.Location,
+		})
+		self.Records = append(self.Records, record)
+	}
+
+	return self
 }
 
-func LatencyRouting() {
-	app := awscdk.NewApp(nil)
+type LatencyTarget struct {
+	Region    string
+	IPAddress string
+	// HealthCheckPath is requested over HTTPS, the target is only answered
+	// while it is healthy. Defaults to /.
+	HealthCheckPath string
+}
 
-	stack := awscdk.NewStack(app, jsii.String("ABTestingStack"), &awscdk.StackProps{
-		Env: &awscdk.Environment{
-			Account: jsii.String("YOUR_ACCOUNT_ID"),
-			Region:  jsii.String("YOUR_REGION"),
-		},
-	})
+type LatencyRoutingProps struct {
+	HostedZoneProps
+	// RecordName, e.g. www.example.com, is also the host name the health
+	// checks send.
+	RecordName string
+	Targets    []LatencyTarget
+}
 
-	awsroute53.NewCfnHealthCheck(stack, jsii.String("MyCfnHealthCheck"), &awsroute53.CfnHealthCheckProps{
-		HealthCheckConfig: &awsroute53.CfnHealthCheck_HealthCheckConfigProperty{
-			Type: jsii.String("type"),
+type LatencyRouting struct {
+	constructs.Construct
+	Zone         awsroute53.IHostedZone
+	Records      []awsroute53.ARecord
+	HealthChecks []awsroute53.CfnHealthCheck
+}
 
-			// the properties below are optional
-			AlarmIdentifier: &awsroute53.CfnHealthCheck_AlarmIdentifierProperty{
-				Name:   jsii.String("name"),
-				Region: jsii.String("region"),
+// NewLatencyRouting answers with the healthy target of the region closest to
+// the client.
+func NewLatencyRouting(scope constructs.Construct, id string, props *LatencyRoutingProps) *LatencyRouting {
+	self := &LatencyRouting{
+		Construct: constructs.NewConstruct(scope, &id),
+	}
+	self.Zone = props.hostedZone(self.Construct)
+
+	for index, target := range props.Targets {
+		path := target.HealthCheckPath
+		if path == "" {
+			path = "/"
+		}
+
+		healthCheck := awsroute53.NewCfnHealthCheck(self.Construct, jsii.Sprintf("HealthCheck%d", index+1), &awsroute53.CfnHealthCheckProps{
+			HealthCheckConfig: &awsroute53.CfnHealthCheck_HealthCheckConfigProperty{
+				Type:                     jsii.String("HTTPS"),
+				IpAddress:                jsii.String(target.IPAddress),
+				Port:                     jsii.Number(443),
+				ResourcePath:             jsii.String(path),
+				FullyQualifiedDomainName: jsii.String(props.RecordName),
+				EnableSni:                jsii.Bool(true),
+				FailureThreshold:         jsii.Number(3),
+				RequestInterval:          jsii.Number(30),
 			},
-			ChildHealthChecks: &[]*string{
-				jsii.String("childHealthChecks"),
-			},
-			EnableSni:                    jsii.Bool(false),
-			FailureThreshold:             jsii.Number(123),
-			FullyQualifiedDomainName:     jsii.String("fullyQualifiedDomainName"),
-			HealthThreshold:              jsii.Number(123),
-			InsufficientDataHealthStatus: jsii.String("insufficientDataHealthStatus"),
-			Inverted:                     jsii.Bool(false),
-			IpAddress:                    jsii.String("ipAddress"),
-			MeasureLatency:               jsii.Bool(false),
-			Port:                         jsii.Number(123),
-			Regions: &[]*string{
-				jsii.String("regions"),
-			},
-			RequestInterval:   jsii.Number(123),
-			ResourcePath:      jsii.String("resourcePath"),
-			RoutingControlArn: jsii.String("routingControlArn"),
-			SearchString:      jsii.String("searchString"),
-		},
-
-		// the properties below are optional
-		HealthCheckTags: []interface{}{
-			&awsroute53.CfnHealthCheck_HealthCheckTagProperty{
-				Key:   jsii.String("key"),
-				Value: jsii.String("value"),
-			},
-		},
-	})
+		})
+
+		record := awsroute53.NewARecord(self.Construct, jsii.Sprintf("ARecordLatency%d", index+1), &awsroute53.ARecordProps{
+			Zone:       self.Zone,
+			RecordName: jsii.String(props.RecordName),
+			Target:     awsroute53.RecordTarget_FromIpAddresses(jsii.String(target.IPAddress)),
+			Region:     jsii.String(target.Region),
+		})
+		// RecordSetOptions has no health check in this CDK version
+		record.Node().DefaultChild().(awsroute53.CfnRecordSet).SetHealthCheckId(healthCheck.AttrHealthCheckId())
+
+		self.Records = append(self.Records, record)
+		self.HealthChecks = append(self.HealthChecks, healthCheck)
+	}
+
+	return self
 }
@@ -0,0 +1,143 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Standard tags use a lower priority than the default of Tags_Of (100), so a
+// tag added on a narrower scope still overrides them.
+const standardTagPriority = 50
+
+// TagPolicy lists the tags every taggable resource must carry and the regular
+// expressions their values must match.
+type TagPolicy struct {
+	Required      []string          `json:"required"`
+	AllowedValues map[string]string `json:"allowedValues"`
+}
+
+// DefaultTagPolicy applies when the config has no tagPolicy.
+var DefaultTagPolicy = TagPolicy{
+	Required: []string{"environment", "project", "author"},
+	AllowedValues: map[string]string{
+		"environment": `^[a-z][a-z0-9-]*$`,
+		"cost-center": `^[A-Za-z0-9-]+$`,
+	},
+}
+
+func (config *Config) tagPolicy() TagPolicy {
+	if config.TagPolicy != nil {
+		return *config.TagPolicy
+	}
+	return DefaultTagPolicy
+}
+
+// ApplyStandardTags tags every taggable resource in scope with the environment,
+// project, author, site, cost-center and owner tags, and fails synth for any
+// resource whose tags break the config's tag policy. Empty values are skipped.
+func ApplyStandardTags(scope constructs.IConstruct, config *Config, environment string, site string) {
+	tags := [][2]string{
+		{"environment", environment},
+		{"project", config.Project},
+		{"author", config.Author},
+		{"site", site},
+		{"cost-center", config.CostCenter},
+		{"owner", config.Owner},
+	}
+	for _, tag := range tags {
+		if tag[1] == "" {
+			continue
+		}
+		awscdk.Tags_Of(scope).Add(jsii.String(tag[0]), jsii.String(tag[1]), &awscdk.TagProps{
+			Priority: jsii.Number(standardTagPriority),
+		})
+	}
+
+	// Checked once synth has applied every tag, including the tags added on
+	// narrower scopes after these, which an aspect on scope would miss
+	scope.Node().AddValidation(newTagPolicyValidation(scope, config))
+}
+
+// tagPatterns compiles the AllowedValues of the tag policy, once per config.
+func (config *Config) tagPatterns() (map[string]*regexp.Regexp, error) {
+	if config.compiledTagPatterns != nil {
+		return config.compiledTagPatterns, nil
+	}
+
+	policy := config.tagPolicy()
+	var keys []string
+	for key := range policy.AllowedValues {
+		keys = append(keys, key)
+	}
+	// Reports the same invalid pattern first on every run
+	slices.Sort(keys)
+	patterns := map[string]*regexp.Regexp{}
+	for _, key := range keys {
+		compiled, err := regexp.Compile(policy.AllowedValues[key])
+		if err != nil {
+			return nil, fmt.Errorf("tag policy pattern for %s: %w", key, err)
+		}
+		patterns[key] = compiled
+	}
+	config.compiledTagPatterns = patterns
+	return patterns, nil
+}
+
+// tagPolicyValidation reports the taggable resources in scope whose tags break
+// the tag policy. It adds errors instead of failing the validation, so synth
+// prints them next to the other errors.
+type tagPolicyValidation struct {
+	scope    constructs.IConstruct
+	required []string
+	patterns map[string]*regexp.Regexp
+	keys     []string
+}
+
+func newTagPolicyValidation(scope constructs.IConstruct, config *Config) *tagPolicyValidation {
+	patterns, err := config.tagPatterns()
+	if err != nil {
+		awscdk.Annotations_Of(scope).AddError(jsii.Sprintf("Config: %s", err))
+	}
+	validation := &tagPolicyValidation{
+		scope:    scope,
+		required: config.tagPolicy().Required,
+		patterns: patterns,
+	}
+	for key := range patterns {
+		validation.keys = append(validation.keys, key)
+	}
+	// Keeps the order of the errors stable
+	slices.Sort(validation.keys)
+	return validation
+}
+
+func (validation *tagPolicyValidation) Validate() *[]*string {
+	for _, node := range *validation.scope.Node().FindAll(constructs.ConstructOrder_PREORDER) {
+		validation.check(node)
+	}
+	return &[]*string{}
+}
+
+func (validation *tagPolicyValidation) check(node constructs.IConstruct) {
+	tagManager := awscdk.TagManager_Of(node)
+	if tagManager == nil {
+		return
+	}
+
+	values := *tagManager.TagValues()
+	for _, key := range validation.required {
+		if value, ok := values[key]; !ok || *value == "" {
+			awscdk.Annotations_Of(node).AddError(jsii.Sprintf("Missing required tag %q", key))
+		}
+	}
+	for _, key := range validation.keys {
+		if value, ok := values[key]; ok && !validation.patterns[key].MatchString(*value) {
+			awscdk.Annotations_Of(node).AddError(jsii.Sprintf("Tag %s=%q does not match %s", key, *value, validation.patterns[key]))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestApplyStandardTagsPolicy(t *testing.T) {
+	newStack := func() (awscdk.Stack, awssns.Topic) {
+		stack := awscdk.NewStack(awscdk.NewApp(nil), jsii.String("tagging"), &awscdk.StackProps{Env: testEnvironment})
+		return stack, awssns.NewTopic(stack, jsii.String("Topic"), nil)
+	}
+
+	t.Run("compliant", func(t *testing.T) {
+		stack, _ := newStack()
+		ApplyStandardTags(stack, testConfig, "dev", "")
+
+		assertAnnotationErrors(t, stack)
+	})
+
+	t.Run("missing required tag", func(t *testing.T) {
+		stack, _ := newStack()
+		config := &Config{Author: "Author", Project: "Template", TagPolicy: &TagPolicy{Required: []string{"owner"}}}
+		ApplyStandardTags(stack, config, "dev", "")
+
+		// The stack carries the tags of its resources too
+		assertAnnotationErrors(t, stack, `/tagging: Missing required tag "owner"`, `/tagging/Topic/Resource: Missing required tag "owner"`)
+	})
+
+	t.Run("value not allowed", func(t *testing.T) {
+		stack, _ := newStack()
+		ApplyStandardTags(stack, testConfig, "Dev_1", "")
+
+		assertAnnotationErrors(t, stack,
+			`/tagging: Tag environment="Dev_1" does not match ^[a-z][a-z0-9-]*$`,
+			`/tagging/Topic/Resource: Tag environment="Dev_1" does not match ^[a-z][a-z0-9-]*$`)
+	})
+
+	t.Run("override on a narrower scope", func(t *testing.T) {
+		stack, topic := newStack()
+		ApplyStandardTags(stack, testConfig, "dev", "")
+		awscdk.Tags_Of(topic).Add(jsii.String("cost-center"), jsii.String("cc 1"), nil)
+
+		assertAnnotationErrors(t, stack, `/tagging/Topic/Resource: Tag cost-center="cc 1" does not match ^[A-Za-z0-9-]+$`)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		stack, _ := newStack()
+		config := &Config{Author: "Author", Project: "Template", TagPolicy: &TagPolicy{AllowedValues: map[string]string{"owner": "("}}}
+		ApplyStandardTags(stack, config, "dev", "")
+
+		assertAnnotationErrors(t, stack, "/tagging: Config: tag policy pattern for owner")
+	})
+}
@@ -0,0 +1,356 @@
+package templates
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssqs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsstepfunctions"
+	"github.com/aws/jsii-runtime-go"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the synthesized templates")
+
+// Asset hashes change with the content of sample-code and lambda, and the
+// logical ids derived from them with the hashes
+var assetHash = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// goldenDir is relative to the module root, see TestMain
+var goldenDir = filepath.Join("templates", "testdata", "golden")
+
+// apiIntegrationRoutes serve a route with every service integration.
+func apiIntegrationRoutes(scope awscdk.Stack) []APIRoute {
+	table := awsdynamodb.Table_FromTableName(scope, jsii.String("Orders"), jsii.String("orders"))
+	return []APIRoute{
+		{
+			Path:        "/jobs",
+			Method:      "POST",
+			Integration: SQSSendMessageIntegration,
+			Queue:       awssqs.Queue_FromQueueArn(scope, jsii.String("Jobs"), jsii.String("arn:aws:sqs:us-east-1:123456789012:jobs")),
+		},
+		{Path: "/orders", Method: "POST", Integration: DynamoDBPutItemIntegration, Table: table, TableKeyAttribute: "orderId"},
+		{Path: "/orders/{orderId}", Method: "GET", Integration: DynamoDBGetItemIntegration, Table: table, TableKeyAttribute: "orderId"},
+		{
+			Path:         "/workflows",
+			Method:       "POST",
+			Integration:  StepFunctionsStartExecutionIntegration,
+			StateMachine: awsstepfunctions.StateMachine_FromStateMachineArn(scope, jsii.String("Workflow"), jsii.String("arn:aws:states:us-east-1:123456789012:stateMachine:workflow")),
+		},
+	}
+}
+
+// dualStackNetwork reaches the internet over IPv6 only.
+func dualStackNetwork(app awscdk.App) *NetworkStack {
+	return NewNetworkStack(app, "network-dual-stack", &NetworkStackProps{
+		StackProps:  awscdk.StackProps{Env: testEnvironment},
+		Environment: "dev",
+		Config:      testConfig,
+		DualStack:   true,
+		NAT:         NoNAT,
+	})
+}
+
+// templateCases synthesize every template with fixed props.
+var templateCases = []struct {
+	name  string
+	build func(app awscdk.App) awscdk.Stack
+}{
+	{"api", func(app awscdk.App) awscdk.Stack {
+		props := apiTestProps()
+		props.Cache.Enabled = jsii.Bool(true)
+		props.Routes = []APIRoute{
+			{Path: "/items/{id}", Method: "GET", Cache: &APIMethodCache{TTLSeconds: 300, QueryStringKeys: []string{"page"}}},
+			{Path: "/", Method: "GET", Cache: &APIMethodCache{TTLSeconds: 60}},
+		}
+		props.Alarms = &APIAlarmSettings{Emails: []string{"ops@example.com"}}
+		props.Tracing = &TracingSettings{LambdaInsights: true}
+		props.Secrets = &LambdaSecretsSettings{
+			Secrets:    map[string]string{"DB_SECRET": "dev/db"},
+			Parameters: map[string]string{"FEATURE_FLAGS": "/dev/flags"},
+		}
+		return NewAPIResources(app, "api", props).Stack
+	}},
+	{"api-integrations", func(app awscdk.App) awscdk.Stack {
+		// The targets usually live in the stacks of the services
+		services := awscdk.NewStack(app, jsii.String("services"), &awscdk.StackProps{Env: testEnvironment})
+		props := apiTestProps()
+		props.Routes = apiIntegrationRoutes(services)
+		return NewAPIResources(app, "api-integrations", props).Stack
+	}},
+	{"api-existing-vpc", func(app awscdk.App) awscdk.Stack {
+		// The VPC and its endpoints usually come from a shared network account
+		shared := awscdk.NewStack(app, jsii.String("shared"), &awscdk.StackProps{Env: testEnvironment})
+		vpc := awsec2.Vpc_FromVpcAttributes(shared, jsii.String("Vpc"), &awsec2.VpcAttributes{
+			VpcId:             jsii.String("vpc-0123456789abcdef0"),
+			AvailabilityZones: jsii.Strings("us-east-1a", "us-east-1b"),
+			PrivateSubnetIds:  jsii.Strings("subnet-0123456789abcdef0", "subnet-0123456789abcdef1"),
+		})
+		endpointSecurityGroup := awsec2.SecurityGroup_FromSecurityGroupId(shared, jsii.String("EndpointSecurityGroup"), jsii.String("sg-0123456789abcdef0"), nil)
+		props := apiTestProps()
+		props.VPC = &LambdaVPCSettings{
+			Vpc: vpc,
+			ExistingEndpoints: map[string]awsec2.IVpcEndpoint{
+				"s3": awsec2.GatewayVpcEndpoint_FromGatewayVpcEndpointId(shared, jsii.String("S3Endpoint"), jsii.String("vpce-0123456789abcdef0")),
+				"secretsmanager": awsec2.InterfaceVpcEndpoint_FromInterfaceVpcEndpointAttributes(shared, jsii.String("SecretsManagerEndpoint"), &awsec2.InterfaceVpcEndpointAttributes{
+					VpcEndpointId:  jsii.String("vpce-0123456789abcdef1"),
+					Port:           jsii.Number(443),
+					SecurityGroups: &[]awsec2.ISecurityGroup{endpointSecurityGroup},
+				}),
+			},
+			// The SNS and SSM endpoints are missing from the shared VPC
+			CreateEndpoints: true,
+		}
+		props.Secrets = &LambdaSecretsSettings{
+			Secrets:    map[string]string{"DB_SECRET": "dev/db"},
+			Parameters: map[string]string{"API_KEY": "/dev/api-key"},
+		}
+		return NewAPIResources(app, "api-existing-vpc", props).Stack
+	}},
+	{"http-api", func(app awscdk.App) awscdk.Stack {
+		props := apiTestProps()
+		props.APIType = HTTPAPI
+		props.JWTAuthorizer = &JWTAuthorizerSettings{
+			Issuer:   "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_example",
+			Audience: []string{"client"},
+		}
+		return NewAPIResources(app, "http-api", props).Stack
+	}},
+	{"http-api-routes", func(app awscdk.App) awscdk.Stack {
+		props := apiTestProps()
+		props.APIType = HTTPAPI
+		props.AllowUnauthenticated = true
+		props.Routes = []APIRoute{
+			{Path: "/items/{id}", Method: "GET"},
+			{Path: "/items", Method: "POST"},
+		}
+		return NewAPIResources(app, "http-api-routes", props).Stack
+	}},
+	{"api-vpc-link", func(app awscdk.App) awscdk.Stack {
+		// Both routes share the frontend of the load balancer
+		orders := isolatedLoadBalancerRoute(app)
+		createOrder := orders
+		createOrder.Method = "POST"
+		props := apiTestProps()
+		props.Routes = []APIRoute{orders, createOrder}
+		return NewAPIResources(app, "api-vpc-link", props).Stack
+	}},
+	{"websocket", func(app awscdk.App) awscdk.Stack {
+		return NewWebSocketAPI(app, "websocket", &PropsWebSocketAPI{
+			StackProps:     awscdk.StackProps{Env: testEnvironment},
+			DomainName:     "example.com",
+			Environment:    "dev",
+			CertificateArn: "arn:aws:acm:us-east-1:123456789012:certificate/00000000-0000-0000-0000-000000000000",
+			HostedZoneId:   "Z0000000000000000000",
+			ApiDomainName:  "ws.example.com",
+			Config:         testConfig,
+		}).Stack
+	}},
+	{"vpc-endpoints", func(app awscdk.App) awscdk.Stack {
+		return NewVPCEndpointStack(app, "vpc-endpoints", &VPCEndpointStackProps{
+			StackProps:  awscdk.StackProps{Env: testEnvironment},
+			Environment: "dev",
+			Config:      testConfig,
+			Resources: EndpointResources{
+				Tables:  []string{"orders"},
+				Secrets: []string{"dev/db"},
+				Topics:  []string{"alarms"},
+			},
+		}).Stack
+	}},
+	{"vpc-endpoints-shared", func(app awscdk.App) awscdk.Stack {
+		// The consumers usually live in the stacks of the workloads
+		workloads := awscdk.NewStack(app, jsii.String("workloads"), &awscdk.StackProps{Env: testEnvironment})
+		consumer := awsec2.SecurityGroup_FromSecurityGroupId(workloads, jsii.String("Consumer"), jsii.String("sg-0123456789abcdef0"), nil)
+		return NewVPCEndpointStack(app, "vpc-endpoints-shared", &VPCEndpointStackProps{
+			StackProps:          awscdk.StackProps{Env: testEnvironment},
+			Environment:         "dev",
+			Config:              testConfig,
+			Endpoints:           IsolatedWorkloadEndpoints(),
+			SharedSecurityGroup: true,
+			Consumers:           []awsec2.ISecurityGroup{consumer},
+		}).Stack
+	}},
+	{"network", func(app awscdk.App) awscdk.Stack {
+		return NewNetworkStack(app, "network", &NetworkStackProps{
+			StackProps:  awscdk.StackProps{Env: testEnvironment},
+			Environment: "dev",
+			Config:      testConfig,
+			FlowLogs:    &FlowLogSettings{TrafficType: awsec2.FlowLogTrafficType_REJECT},
+			NetworkACLs: []NetworkACLRuleSet{DatabaseSubnetsACL("203.0.113.0/24")},
+		}).Stack
+	}},
+	{"network-ipam", func(app awscdk.App) awscdk.Stack {
+		return NewNetworkStack(app, "network-ipam", &NetworkStackProps{
+			StackProps:        awscdk.StackProps{Env: testEnvironment},
+			Environment:       "dev",
+			Config:            testConfig,
+			IpamPoolId:        "ipam-pool-0123456789abcdef0",
+			IpamNetmaskLength: 20,
+			AZs:               3,
+			SubnetMasks:       SubnetMasks{Public: 26, Private: 23, Isolated: 25},
+			NAT:               SingleNATGateway,
+			FlowLogs:          &FlowLogSettings{Destination: FlowLogsToS3, PerHourPartitions: true},
+		}).Stack
+	}},
+	{"network-shared-services", func(app awscdk.App) awscdk.Stack {
+		return NewNetworkStack(app, "network-shared-services", &NetworkStackProps{
+			StackProps:  awscdk.StackProps{Env: testEnvironment},
+			Environment: "dev",
+			Config:      testConfig,
+			TransitGateway: &TransitGatewaySettings{
+				TransitGatewayId:         "tgw-0123456789abcdef0",
+				ApplianceMode:            true,
+				PropagationRouteTableIds: []string{"tgw-rtb-0123456789abcdef0"},
+				Routes:                   []string{"10.100.0.0/16"},
+			},
+			Peerings: []VpcPeeringSettings{{
+				Name:        "SharedServices",
+				PeerVpcId:   "vpc-0123456789abcdef0",
+				PeerCidr:    "10.200.0.0/16",
+				PeerOwnerId: "210987654321",
+				PeerRoleArn: "arn:aws:iam::210987654321:role/vpc-peering-accepter",
+			}},
+		}).Stack
+	}},
+	{"network-dual-stack", func(app awscdk.App) awscdk.Stack {
+		return dualStackNetwork(app).Stack
+	}},
+	{"elasticache-dual-stack", func(app awscdk.App) awscdk.Stack {
+		network := dualStackNetwork(app)
+		stack := awscdk.NewStack(app, jsii.String("elasticache-dual-stack"), &awscdk.StackProps{Env: testEnvironment})
+		NewElastiCache(stack, "Cache", &ElastiCacheProps{
+			ExistingVpcProps: ExistingVpcProps{Vpc: network.Vpc},
+			Environment:      "dev",
+			Config:           testConfig,
+		})
+		return stack
+	}},
+	{"elasticache", func(app awscdk.App) awscdk.Stack {
+		stack := awscdk.NewStack(app, jsii.String("elasticache"), &awscdk.StackProps{Env: testEnvironment})
+		NewElastiCache(stack, "Cache", &ElastiCacheProps{Environment: "dev", Config: testConfig})
+		return stack
+	}},
+	{"elasticache-existing-vpc", func(app awscdk.App) awscdk.Stack {
+		stack := awscdk.NewStack(app, jsii.String("elasticache-existing-vpc"), &awscdk.StackProps{Env: testEnvironment})
+		// Without cached context the lookup returns the dummy VPC of the CDK
+		NewElastiCache(stack, "Cache", &ElastiCacheProps{
+			ExistingVpcProps: ExistingVpcProps{VpcName: "shared"},
+			Environment:      "dev",
+			Config:           testConfig,
+		})
+		return stack
+	}},
+	{"rds-elasticache", func(app awscdk.App) awscdk.Stack {
+		stack := awscdk.NewStack(app, jsii.String("rds-elasticache"), &awscdk.StackProps{Env: testEnvironment})
+		NewRDSElastiCache(stack, "Database", &RDSElastiCacheProps{Environment: "dev", Config: testConfig})
+		return stack
+	}},
+	{"routing-route53", func(app awscdk.App) awscdk.Stack {
+		stack := awscdk.NewStack(app, jsii.String("routing-route53"), &awscdk.StackProps{Env: testEnvironment})
+		zone := HostedZoneProps{ZoneName: "example.com"}
+		NewCNamePolicies(stack, "CName", &CNamePoliciesProps{
+			HostedZoneProps: zone,
+			RecordName:      "sub.example.com",
+			Targets:         []WeightedTarget{{DomainName: "a.example.com", Weight: 50}, {DomainName: "b.example.com", Weight: 50}},
+		})
+		NewARecord(stack, "A", &ARecordProps{
+			HostedZoneProps: zone,
+			RecordName:      "www",
+			IPAddresses:     []string{"192.0.2.1", "192.0.2.2"},
+		})
+		NewGeoLocationRouting(stack, "Geo", &GeoLocationRoutingProps{
+			HostedZoneProps: zone,
+			RecordName:      "geo",
+			Locations: []GeoLocationTarget{
+				{Location: awsroute53.GeoLocation_Continent(awsroute53.Continent_EUROPE), IPAddresses: []string{"192.0.2.10"}},
+				{Location: awsroute53.GeoLocation_Country(jsii.String("DE")), IPAddresses: []string{"192.0.2.11"}},
+			},
+			DefaultIPAddresses: []string{"192.0.2.12"},
+		})
+		NewLatencyRouting(stack, "Latency", &LatencyRoutingProps{
+			HostedZoneProps: zone,
+			RecordName:      "latency.example.com",
+			Targets: []LatencyTarget{
+				{Region: "us-east-1", IPAddress: "192.0.2.20"},
+				{Region: "eu-west-1", IPAddress: "192.0.2.21", HealthCheckPath: "/health"},
+			},
+		})
+		return stack
+	}},
+}
+
+func TestTemplates(t *testing.T) {
+	for _, tc := range templateCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := awscdk.NewApp(nil)
+			stack := tc.build(app)
+
+			for _, message := range annotationErrors(stack) {
+				t.Error(message)
+			}
+
+			template, err := json.MarshalIndent(assertions.Template_FromStack(stack, nil).ToJSON(), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := assetHash.ReplaceAllString(string(template), "ASSET_HASH") + "\n"
+
+			path := filepath.Join(goldenDir, tc.name+".json")
+			if *update {
+				if err := os.MkdirAll(goldenDir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v, run go test ./templates -update to create it", err)
+			}
+			if got != string(want) {
+				t.Errorf("%s differs from the synthesized template, review the change and run go test ./templates -update\n%s",
+					path, firstDifference(string(want), got))
+			}
+		})
+	}
+}
+
+// firstDifference returns the first line where got differs from want with the
+// lines around it, which is usually enough to find the change in the template.
+func firstDifference(want string, got string) string {
+	const context = 3
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+
+	line := 0
+	for line < len(wantLines) && line < len(gotLines) && wantLines[line] == gotLines[line] {
+		line++
+	}
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "first difference at line %d\n", line+1)
+	for i := max(line-context, 0); i < line; i++ {
+		fmt.Fprintf(&report, "  %s\n", wantLines[i])
+	}
+	for i := line; i < min(line+context, len(wantLines)); i++ {
+		fmt.Fprintf(&report, "- %s\n", wantLines[i])
+	}
+	for i := line; i < min(line+context, len(gotLines)); i++ {
+		fmt.Fprintf(&report, "+ %s\n", gotLines[i])
+	}
+	return report.String()
+}
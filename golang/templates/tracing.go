@@ -0,0 +1,90 @@
+package templates
+
+import (
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsxray"
+	"github.com/aws/jsii-runtime-go"
+
+	"cdk-template/naming"
+)
+
+// TracingSettings turns on X-Ray for the API stage and the Lambda function.
+// The Lambda Insights and ADOT layers are resolved by CDK for the region and
+// the function's LambdaArchitecture.
+type TracingSettings struct {
+	// SamplingRate is the fixed rate of the sampling rule, defaults to 0.05 in
+	// production and 1 (every request) elsewhere.
+	SamplingRate *float64
+	// ReservoirSize is the number of requests per second traced before the
+	// fixed rate applies, defaults to 1.
+	ReservoirSize  float64
+	LambdaInsights bool
+	ADOT           bool
+}
+
+func (settings *TracingSettings) samplingRate(isProduction bool) float64 {
+	if settings.SamplingRate != nil {
+		return *settings.SamplingRate
+	}
+	if isProduction {
+		return 0.05
+	}
+	return 1
+}
+
+func (settings *TracingSettings) reservoirSize() float64 {
+	if settings.ReservoirSize == 0 {
+		return 1
+	}
+	return settings.ReservoirSize
+}
+
+// applyTracing enables active tracing and the optional layers on the function.
+// CDK adds the X-Ray and Lambda Insights permissions to the function role.
+func applyTracing(functionProps *awslambda.FunctionProps, settings *TracingSettings) {
+	if settings == nil {
+		return
+	}
+
+	functionProps.Tracing = awslambda.Tracing_ACTIVE
+	if settings.LambdaInsights {
+		functionProps.InsightsVersion = awslambda.LambdaInsightsVersion_VERSION_1_0_229_0()
+	}
+	if settings.ADOT {
+		// The generic layer carries the collector, the Go SDK is compiled into the binary
+		functionProps.AdotInstrumentation = &awslambda.AdotInstrumentationConfig{
+			LayerVersion: awslambda.AdotLayerVersion_FromGenericLayerVersion(awslambda.AdotLambdaLayerGenericVersion_LATEST()),
+			ExecWrapper:  awslambda.AdotLambdaExecWrapper_REGULAR_HANDLER,
+		}
+	}
+}
+
+// createSamplingRule samples the requests sent to the API's custom domain.
+// Rule names are unique per account and region, so the name includes the
+// domain of the API.
+func (self *APIResources) createSamplingRule(props *PropsAPIResources) {
+	if props.Tracing == nil {
+		return
+	}
+
+	awsxray.NewCfnSamplingRule(self.Stack, jsii.String("SamplingRule"), &awsxray.CfnSamplingRuleProps{
+		SamplingRule: &awsxray.CfnSamplingRule_SamplingRuleProperty{
+			RuleName:      jsii.String(self.names.Name(naming.XRaySamplingRule, props.ApiDomainName)),
+			Priority:      jsii.Number(1000),
+			FixedRate:     jsii.Number(props.Tracing.samplingRate(props.IsProduction)),
+			ReservoirSize: jsii.Number(props.Tracing.reservoirSize()),
+			Host:          jsii.String(props.ApiDomainName),
+			HttpMethod:    jsii.String("*"),
+			UrlPath:       jsii.String("*"),
+			ServiceName:   jsii.String("*"),
+			ServiceType:   jsii.String("*"),
+			ResourceArn:   jsii.String("*"),
+			Version:       jsii.Number(1),
+		},
+	})
+
+	if props.APIType == HTTPAPI {
+		awscdk.Annotations_Of(self.Stack).AddWarning(jsii.String("HTTP APIs do not support X-Ray, only the Lambda function is traced"))
+	}
+}
@@ -0,0 +1,250 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// EndpointConditions limit who may use an endpoint. Each non-empty field adds
+// a StringEquals condition, all of them have to match.
+type EndpointConditions struct {
+	// PrincipalOrgID is the o-xxxxxxxxxx id of the organization.
+	PrincipalOrgID string
+	// SourceVpc is the vpc-xxxxxxxx id the requests come from, e.g. vpc.VpcId().
+	SourceVpc string
+	// PrincipalAccounts default to none, awscdk.Aws_ACCOUNT_ID() is the account
+	// of the stack.
+	PrincipalAccounts []string
+}
+
+func (conditions *EndpointConditions) toJSON() *map[string]interface{} {
+	if conditions == nil {
+		return nil
+	}
+	equals := map[string]interface{}{}
+	if conditions.PrincipalOrgID != "" {
+		equals["aws:PrincipalOrgID"] = conditions.PrincipalOrgID
+	}
+	if conditions.SourceVpc != "" {
+		equals["aws:SourceVpc"] = conditions.SourceVpc
+	}
+	if len(conditions.PrincipalAccounts) > 0 {
+		equals["aws:PrincipalAccount"] = conditions.PrincipalAccounts
+	}
+	if len(equals) == 0 {
+		return nil
+	}
+	return &map[string]interface{}{"StringEquals": equals}
+}
+
+// The statement helpers take the names of the resources, names with wildcards
+// are rejected by the endpoint policy check like a "*" resource.
+
+// DynamoDBTablesStatement allows actions on the named tables and their indexes
+// in the account and region of the stack. Actions default to reading and
+// writing items.
+func DynamoDBTablesStatement(tables []string, actions []string, conditions *EndpointConditions) awsiam.PolicyStatement {
+	if len(actions) == 0 {
+		actions = []string{
+			"dynamodb:GetItem", "dynamodb:BatchGetItem", "dynamodb:Query", "dynamodb:Scan",
+			"dynamodb:PutItem", "dynamodb:UpdateItem", "dynamodb:DeleteItem", "dynamodb:BatchWriteItem",
+		}
+	}
+	var resources []string
+	for _, table := range tables {
+		arn := serviceArn("dynamodb", "table/"+table)
+		resources = append(resources, arn, arn+"/index/*")
+	}
+	return endpointStatement(actions, resources, conditions)
+}
+
+// SecretsStatement allows actions on the named secrets of the account and
+// region of the stack. Actions default to secretsmanager:GetSecretValue.
+func SecretsStatement(secrets []string, actions []string, conditions *EndpointConditions) awsiam.PolicyStatement {
+	if len(actions) == 0 {
+		actions = []string{"secretsmanager:GetSecretValue"}
+	}
+	var resources []string
+	for _, secret := range secrets {
+		// Secrets Manager appends six random characters to the name
+		resources = append(resources, serviceArn("secretsmanager", "secret:"+secret+"-??????"))
+	}
+	return endpointStatement(actions, resources, conditions)
+}
+
+// SNSTopicsStatement allows actions on the named topics of the account and
+// region of the stack. Actions default to sns:Publish.
+func SNSTopicsStatement(topics []string, actions []string, conditions *EndpointConditions) awsiam.PolicyStatement {
+	if len(actions) == 0 {
+		actions = []string{"sns:Publish"}
+	}
+	var resources []string
+	for _, topic := range topics {
+		resources = append(resources, serviceArn("sns", topic))
+	}
+	return endpointStatement(actions, resources, conditions)
+}
+
+// SSMParametersStatement allows actions on the named parameters of the
+// account and region of the stack. Actions default to reading them.
+func SSMParametersStatement(parameters []string, actions []string, conditions *EndpointConditions) awsiam.PolicyStatement {
+	if len(actions) == 0 {
+		actions = []string{"ssm:GetParameter", "ssm:GetParameters"}
+	}
+	var resources []string
+	for _, parameter := range parameters {
+		// Hierarchical names keep their leading slash out of the ARN
+		resources = append(resources, serviceArn("ssm", "parameter/"+strings.TrimPrefix(parameter, "/")))
+	}
+	return endpointStatement(actions, resources, conditions)
+}
+
+// S3BucketsStatement allows actions on the named buckets and their objects.
+// Actions default to listing, reading and writing objects.
+func S3BucketsStatement(buckets []string, actions []string, conditions *EndpointConditions) awsiam.PolicyStatement {
+	if len(actions) == 0 {
+		actions = []string{"s3:ListBucket", "s3:GetObject", "s3:PutObject", "s3:DeleteObject"}
+	}
+	var resources []string
+	for _, bucket := range buckets {
+		arn := fmt.Sprintf("arn:%s:s3:::%s", *awscdk.Aws_PARTITION(), bucket)
+		resources = append(resources, arn, arn+"/*")
+	}
+	return endpointStatement(actions, resources, conditions)
+}
+
+// serviceArn builds arn:<partition>:<service>:<region>:<account>:<resource>
+// from the pseudo parameters of the stack.
+func serviceArn(service string, resource string) string {
+	return fmt.Sprintf("arn:%s:%s:%s:%s:%s", *awscdk.Aws_PARTITION(), service, *awscdk.Aws_REGION(), *awscdk.Aws_ACCOUNT_ID(), resource)
+}
+
+// endpointStatement lets any principal passing the conditions use the
+// resources, the identity policies of the principals still apply.
+func endpointStatement(actions []string, resources []string, conditions *EndpointConditions) awsiam.PolicyStatement {
+	return awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Principals: &[]awsiam.IPrincipal{awsiam.NewAnyPrincipal()},
+		Actions:    jsii.Strings(actions...),
+		Resources:  jsii.Strings(resources...),
+		Conditions: conditions.toJSON(),
+	})
+}
+
+// endpointPolicyAspect rejects endpoints without a policy, which AWS gives
+// full access, and policies allowing any principal on any resource, which are
+// as open as having none. Resources with a wildcard in their name, e.g. every
+// table of the account, count as any resource.
+type endpointPolicyAspect struct {
+	service string
+}
+
+func (aspect *endpointPolicyAspect) Visit(node constructs.IConstruct) {
+	endpoint, ok := node.(awsec2.CfnVPCEndpoint)
+	if !ok {
+		return
+	}
+
+	// The L2 endpoints pass a lazy policy that resolves to nothing without
+	// statements
+	var document map[string]interface{}
+	if endpoint.PolicyDocument() != nil {
+		document, _ = awscdk.Stack_Of(node).Resolve(endpoint.PolicyDocument()).(map[string]interface{})
+	}
+	if document == nil {
+		awscdk.Annotations_Of(node).AddError(jsii.Sprintf(
+			"Endpoint %s has no policy, which allows any principal on all resources, set PolicyStatements or AllowWildcardPolicy",
+			aspect.service))
+		return
+	}
+	statements, _ := document["Statement"].([]interface{})
+	for index, statement := range statements {
+		statement, _ := statement.(map[string]interface{})
+		if statement["Effect"] == "Allow" && isAnyResource(statement["Resource"]) && isAnyPrincipal(statement["Principal"]) {
+			awscdk.Annotations_Of(node).AddError(jsii.Sprintf(
+				"Endpoint policy statement %d allows any principal on all resources, scope it to named resources with DynamoDBTablesStatement, SecretsStatement, SNSTopicsStatement, SSMParametersStatement or S3BucketsStatement or set AllowWildcardPolicy",
+				index+1))
+		}
+	}
+}
+
+func isAnyPrincipal(principal interface{}) bool {
+	if principals, ok := principal.(map[string]interface{}); ok {
+		principal = principals["AWS"]
+	}
+	return isWildcard(principal)
+}
+
+// Services whose ARNs name the resource without a resource type, e.g.
+// arn:aws:sns:us-east-1:123456789012:alarms
+var untypedArnServices = map[string]bool{"s3": true, "sns": true, "sqs": true}
+
+// isAnyResource reports "*" and ARNs with a wildcard in the resource name,
+// e.g. table/* or secret:*. Wildcards after the name, e.g. the indexes of a
+// table or the objects of a bucket, are allowed.
+func isAnyResource(value interface{}) bool {
+	resources, ok := value.([]interface{})
+	if !ok {
+		resources = []interface{}{value}
+	}
+	for _, resource := range resources {
+		resource := flattenResolved(resource)
+		if resource == "*" {
+			return true
+		}
+		parts := strings.SplitN(resource, ":", 6)
+		if len(parts) < 6 || parts[0] != "arn" {
+			continue
+		}
+		name := parts[5]
+		if index := strings.IndexAny(name, "/:"); index >= 0 && !untypedArnServices[parts[2]] {
+			name = name[index+1:]
+		}
+		if index := strings.IndexAny(name, "/:"); index >= 0 {
+			name = name[:index]
+		}
+		if strings.Contains(name, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenResolved turns a resolved value, e.g. an ARN joined from the pseudo
+// parameters, into a string with # for the values only known at deployment.
+func flattenResolved(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case map[string]interface{}:
+		if join, ok := value["Fn::Join"].([]interface{}); ok && len(join) == 2 {
+			separator, _ := join[0].(string)
+			parts, _ := join[1].([]interface{})
+			var flattened []string
+			for _, part := range parts {
+				flattened = append(flattened, flattenResolved(part))
+			}
+			return strings.Join(flattened, separator)
+		}
+	}
+	return "#"
+}
+
+func isWildcard(value interface{}) bool {
+	switch value := value.(type) {
+	case string:
+		return value == "*"
+	case []interface{}:
+		for _, item := range value {
+			if item == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestEndpointPolicyCheck(t *testing.T) {
+	wildcard := func() awsiam.PolicyStatement {
+		return awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Principals: &[]awsiam.IPrincipal{awsiam.NewAnyPrincipal()},
+			Actions:    jsii.Strings("sqs:*"),
+			Resources:  jsii.Strings("*"),
+		})
+	}
+	tests := []struct {
+		name  string
+		spec  EndpointSpec
+		wants []string
+	}{
+		{"scoped", EndpointSpec{Service: "sns", PolicyStatements: []awsiam.PolicyStatement{SNSTopicsStatement([]string{"alarms"}, nil, nil)}}, nil},
+		{"no policy", EndpointSpec{Service: "sqs"}, []string{"/endpoints/SqsEndpoint/Resource: Endpoint sqs has no policy"}},
+		{"no gateway policy", EndpointSpec{Service: "s3", Type: GatewayEndpoint}, []string{"/endpoints/S3Endpoint/Resource: Endpoint s3 has no policy"}},
+		{"wildcard", EndpointSpec{Service: "sqs", PolicyStatements: []awsiam.PolicyStatement{
+			SNSTopicsStatement([]string{"alarms"}, nil, nil), wildcard(),
+		}}, []string{"/endpoints/SqsEndpoint/Resource: Endpoint policy statement 2 allows any principal on all resources"}},
+		{"waived wildcard", EndpointSpec{Service: "sqs", PolicyStatements: []awsiam.PolicyStatement{wildcard()}, AllowWildcardPolicy: true}, nil},
+		{"waived no policy", EndpointSpec{Service: "sqs", AllowWildcardPolicy: true}, nil},
+		{"wildcard table", EndpointSpec{Service: "dynamodb", Type: GatewayEndpoint, PolicyStatements: []awsiam.PolicyStatement{
+			DynamoDBTablesStatement([]string{"*"}, nil, nil),
+		}}, []string{"/endpoints/DynamodbEndpoint/Resource: Endpoint policy statement 1 allows any principal on all resources"}},
+		{"wildcard secret", EndpointSpec{Service: "secretsmanager", PolicyStatements: []awsiam.PolicyStatement{
+			SecretsStatement([]string{"*"}, nil, nil),
+		}}, []string{"/endpoints/SecretsmanagerEndpoint/Resource: Endpoint policy statement 1 allows any principal on all resources"}},
+		{"wildcard topic", EndpointSpec{Service: "sns", PolicyStatements: []awsiam.PolicyStatement{
+			SNSTopicsStatement([]string{"*"}, nil, nil),
+		}}, []string{"/endpoints/SnsEndpoint/Resource: Endpoint policy statement 1 allows any principal on all resources"}},
+		{"named table", EndpointSpec{Service: "dynamodb", Type: GatewayEndpoint, PolicyStatements: []awsiam.PolicyStatement{
+			DynamoDBTablesStatement([]string{"orders"}, nil, nil),
+		}}, nil},
+		{"named bucket", EndpointSpec{Service: "s3", Type: GatewayEndpoint, PolicyStatements: []awsiam.PolicyStatement{
+			S3BucketsStatement([]string{"archive"}, nil, nil),
+		}}, nil},
+		{"named parameter", EndpointSpec{Service: "ssm", PolicyStatements: []awsiam.PolicyStatement{
+			SSMParametersStatement([]string{"/dev/api-key"}, nil, nil),
+		}}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stack := awscdk.NewStack(awscdk.NewApp(nil), jsii.String("endpoints"), &awscdk.StackProps{Env: testEnvironment})
+			vpc := awsec2.NewVpc(stack, jsii.String("Vpc"), nil)
+			createEndpoint(stack, vpc, test.spec, nil)
+
+			assertAnnotationErrors(t, stack, test.wants...)
+		})
+	}
+}
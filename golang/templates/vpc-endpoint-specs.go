@@ -0,0 +1,178 @@
+package templates
+
+import (
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// EndpointType selects between an interface endpoint (the default) and a
+// gateway endpoint, which only S3 and DynamoDB offer.
+type EndpointType string
+
+const (
+	InterfaceEndpoint EndpointType = ""
+	GatewayEndpoint   EndpointType = "gateway"
+)
+
+// EndpointSpec describes a VPC endpoint created by NewVPCEndpointStack.
+type EndpointSpec struct {
+	// Service is the service part of com.amazonaws.<region>.<service>, e.g.
+	// "s3", "ecr.api" or "logs".
+	Service string
+	Type    EndpointType
+	// Subnets places an interface endpoint, one subnet per AZ, and selects the
+	// route tables of a gateway endpoint. Defaults to the private subnets for
+	// interface endpoints and to every subnet for gateway endpoints.
+	Subnets *awsec2.SubnetSelection
+	// PrivateDns defaults to true for interface endpoints.
+	PrivateDns *bool
+	// PolicyStatements replace the default full access endpoint policy, see
+	// DynamoDBTablesStatement, SecretsStatement and SNSTopicsStatement.
+	PolicyStatements []awsiam.PolicyStatement
+	// AllowWildcardPolicy waives the check that rejects endpoints without
+	// PolicyStatements and statements allowing any principal on all resources.
+	AllowWildcardPolicy bool
+	// SecurityGroups are existing groups of an interface endpoint, used instead
+	// of the group created by the stack.
+	SecurityGroups []awsec2.ISecurityGroup
+}
+
+// EndpointResources names the resources the policies of DefaultEndpoints
+// allow.
+type EndpointResources struct {
+	Tables  []string
+	Secrets []string
+	Topics  []string
+}
+
+// DefaultEndpoints are created when VPCEndpointStackProps.Endpoints is empty.
+// Their policies only admit principals of the stack's account to the named
+// resources, an endpoint without names has no policy and is reported. The
+// DynamoDB endpoint no longer allows dynamodb:ListTables, which only works on
+// all tables of the account.
+func DefaultEndpoints(resources EndpointResources) []EndpointSpec {
+	account := &EndpointConditions{PrincipalAccounts: []string{*awscdk.Aws_ACCOUNT_ID()}}
+	specs := []EndpointSpec{
+		{Service: "dynamodb", Type: GatewayEndpoint},
+		{Service: "secretsmanager"},
+		{Service: "sns"},
+	}
+	if len(resources.Tables) > 0 {
+		specs[0].PolicyStatements = []awsiam.PolicyStatement{DynamoDBTablesStatement(resources.Tables, []string{"dynamodb:DescribeTable"}, account)}
+	}
+	if len(resources.Secrets) > 0 {
+		specs[1].PolicyStatements = []awsiam.PolicyStatement{SecretsStatement(resources.Secrets, nil, account)}
+	}
+	if len(resources.Topics) > 0 {
+		specs[2].PolicyStatements = []awsiam.PolicyStatement{SNSTopicsStatement(resources.Topics, nil, account)}
+	}
+	return specs
+}
+
+// IsolatedWorkloadEndpoints lets workloads in subnets without internet access
+// pull images from ECR, write logs, assume roles and use S3, KMS and SQS. The
+// resources depend on the workloads, so the endpoints keep the full access
+// policy and only the consumers allowed by the security groups reach them.
+// Replace the policies with PolicyStatements to limit the resources.
+func IsolatedWorkloadEndpoints() []EndpointSpec {
+	return []EndpointSpec{
+		// ECR stores the image layers in S3
+		{Service: "s3", Type: GatewayEndpoint, AllowWildcardPolicy: true},
+		{Service: "ecr.api", AllowWildcardPolicy: true},
+		{Service: "ecr.dkr", AllowWildcardPolicy: true},
+		{Service: "logs", AllowWildcardPolicy: true},
+		{Service: "sts", AllowWildcardPolicy: true},
+		{Service: "kms", AllowWildcardPolicy: true},
+		{Service: "sqs", AllowWildcardPolicy: true},
+	}
+}
+
+// endpointID turns a service such as ecr.api into EcrApiEndpoint.
+func endpointID(service string) string {
+	parts := strings.FieldsFunc(service, func(r rune) bool { return r == '.' || r == '-' })
+	for index, part := range parts {
+		parts[index] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.Join(parts, "") + "Endpoint"
+}
+
+// createEndpoint creates the endpoint described by spec in the VPC. Interface
+// endpoints use spec.SecurityGroups, otherwise securityGroups, otherwise a new
+// group of their own. None of them is opened to the VPC CIDR.
+func createEndpoint(scope constructs.Construct, vpc awsec2.IVpc, spec EndpointSpec, securityGroups []awsec2.ISecurityGroup) awsec2.IVpcEndpoint {
+	id := jsii.String(endpointID(spec.Service))
+
+	if spec.Subnets != nil && !validSubnetSelection(scope, vpc, spec.Subnets, "Endpoint "+spec.Service) {
+		return nil
+	}
+
+	var endpoint awsec2.IVpcEndpoint
+	var addToPolicy func(statement awsiam.PolicyStatement)
+
+	switch spec.Type {
+	case GatewayEndpoint:
+		if spec.Service != "s3" && spec.Service != "dynamodb" {
+			awscdk.Annotations_Of(scope).AddError(jsii.Sprintf("Endpoint %s: only s3 and dynamodb support gateway endpoints", spec.Service))
+			return nil
+		}
+		if spec.PrivateDns != nil {
+			awscdk.Annotations_Of(scope).AddWarning(jsii.Sprintf("Endpoint %s: PrivateDns only applies to interface endpoints", spec.Service))
+		}
+		if len(spec.SecurityGroups) > 0 {
+			awscdk.Annotations_Of(scope).AddWarning(jsii.Sprintf("Endpoint %s: SecurityGroups only apply to interface endpoints", spec.Service))
+		}
+		var subnets *[]*awsec2.SubnetSelection
+		if spec.Subnets != nil {
+			subnets = &[]*awsec2.SubnetSelection{spec.Subnets}
+		}
+		gateway := awsec2.NewGatewayVpcEndpoint(scope, id, &awsec2.GatewayVpcEndpointProps{
+			Vpc:     vpc,
+			Service: awsec2.NewGatewayVpcEndpointAwsService(jsii.String(spec.Service), nil),
+			Subnets: subnets,
+		})
+		endpoint, addToPolicy = gateway, func(statement awsiam.PolicyStatement) { gateway.AddToPolicy(statement) }
+	case InterfaceEndpoint:
+		if len(spec.SecurityGroups) > 0 {
+			securityGroups = spec.SecurityGroups
+		}
+		if len(securityGroups) == 0 {
+			securityGroups = []awsec2.ISecurityGroup{newEndpointSecurityGroup(scope, vpc, *id+"SecurityGroup", spec.Service+" endpoint")}
+		}
+		iface := awsec2.NewInterfaceVpcEndpoint(scope, id, &awsec2.InterfaceVpcEndpointProps{
+			Vpc:               vpc,
+			Service:           awsec2.NewInterfaceVpcEndpointAwsService(jsii.String(spec.Service), nil, nil),
+			Subnets:           spec.Subnets,
+			PrivateDnsEnabled: spec.PrivateDns,
+			SecurityGroups:    &securityGroups,
+			Open:              jsii.Bool(false),
+		})
+		endpoint, addToPolicy = iface, func(statement awsiam.PolicyStatement) { iface.AddToPolicy(statement) }
+	default:
+		awscdk.Annotations_Of(scope).AddError(jsii.Sprintf("Endpoint %s: unknown endpoint type %q", spec.Service, spec.Type))
+		return nil
+	}
+
+	for _, statement := range spec.PolicyStatements {
+		addToPolicy(statement)
+	}
+	if !spec.AllowWildcardPolicy {
+		awscdk.Aspects_Of(endpoint).Add(&endpointPolicyAspect{service: spec.Service})
+	}
+
+	return endpoint
+}
+
+// newEndpointSecurityGroup creates a group without rules, the consumers are
+// allowed in by the stack. Endpoints never open connections themselves.
+func newEndpointSecurityGroup(scope constructs.Construct, vpc awsec2.IVpc, id string, description string) awsec2.SecurityGroup {
+	return awsec2.NewSecurityGroup(scope, jsii.String(id), &awsec2.SecurityGroupProps{
+		Vpc:              vpc,
+		Description:      jsii.String(description),
+		AllowAllOutbound: jsii.Bool(false),
+	})
+}
@@ -11,86 +11,117 @@ import (
 )
 
 type VPCEndpointStackProps struct {
-	constructs.Construct
+	awscdk.StackProps
+	// ExistingVpcProps default to a new VPC with public, private and isolated
+	// subnets in two AZs.
+	ExistingVpcProps
+	Environment string
+	Config      *Config
+	// LambdaCodePath is the directory of the Node.js handler, defaults to lambda.
+	LambdaCodePath string
+	// Endpoints defaults to DefaultEndpoints, see also IsolatedWorkloadEndpoints.
+	Endpoints []EndpointSpec
+	// Resources are the tables, secrets and topics of DefaultEndpoints.
+	Resources EndpointResources
+	// SharedSecurityGroup creates one security group for all interface
+	// endpoints instead of one per endpoint.
+	SharedSecurityGroup bool
+	// EndpointSecurityGroup is an existing group shared by the interface
+	// endpoints without SecurityGroups of their own.
+	EndpointSecurityGroup awsec2.ISecurityGroup
+	// Consumers are allowed to reach the interface endpoints on port 443, next
+	// to the Lambda function.
+	Consumers []awsec2.ISecurityGroup
+	// LambdaSecurityGroup is an existing group for the Lambda function, a new
+	// one is created by default.
+	LambdaSecurityGroup awsec2.ISecurityGroup
 }
 
-func NewVPCEndpointStack(scope constructs.Construct, id string, props *VPCEndpointStackProps) constructs.Construct {
-	stack := constructs.NewConstruct(scope, &id)
-
-	// Create a VPC
-	vpc := awsec2.NewVpc(stack, jsii.String("MyVPC"), &awsec2.VpcProps{
-		MaxAzs: jsii.Number(2),
-		SubnetConfiguration: &[]*awsec2.SubnetConfiguration{
-			{
-				CidrMask:   jsii.Number(24),
-				Name:       jsii.String("Public"),
-				SubnetType: awsec2.SubnetType_PUBLIC,
-			},
-			{
-				CidrMask:   jsii.Number(24),
-				Name:       jsii.String("Private"),
-				SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS,
-			},
-			{
-				CidrMask:   jsii.Number(24),
-				Name:       jsii.String("Private"),
-				SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED,
-			},
-		},
-	})
-
-	// Create DynamoDB Gateway VPC Endpoint
-	dynamoDbGatewayEndpoint := awsec2.NewGatewayVpcEndpoint(stack, jsii.String("DynamoDbEndpoint"), &awsec2.GatewayVpcEndpointProps{
-		Vpc:     vpc,
-		Service: awsec2.GatewayVpcEndpointAwsService_DYNAMODB(),
-	})
-
-	// Create Secrets Manager Interface VPC Endpoint
-	secretsManagerEndpoint := awsec2.NewInterfaceVpcEndpoint(stack, jsii.String("SecretsManagerEndpoint"), &awsec2.InterfaceVpcEndpointProps{
-		Vpc:     vpc,
-		Service: awsec2.InterfaceVpcEndpointAwsService_SECRETS_MANAGER(),
-		SecurityGroups: &[]awsec2.ISecurityGroup{
-			awsec2.SecurityGroup_FromSecurityGroupId(stack, jsii.String("SecretsManagerVPCEndpointSG"), jsii.String("sg-exampleID"), &awsec2.SecurityGroupImportOptions{}),
-		},
-	})
-
-	// Create Secrets Manager Interface VPC Endpoint
-	snsEndpoint := awsec2.NewInterfaceVpcEndpoint(stack, jsii.String("SNSEndpoint"), &awsec2.InterfaceVpcEndpointProps{
-		Vpc:     vpc,
-		Service: awsec2.InterfaceVpcEndpointAwsService_SNS(),
-		SecurityGroups: &[]awsec2.ISecurityGroup{
-			awsec2.SecurityGroup_FromSecurityGroupId(stack, jsii.String("SNSVPCEndpointSG"), jsii.String("sg-exampleID"), &awsec2.SecurityGroupImportOptions{}),
-		},
-	})
-
-	// Customize endpoint policies
-	dynamoDbGatewayEndpoint.AddToPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
-		Principals: &[]awsiam.IPrincipal{awsiam.NewAnyPrincipal()},
-		Actions:    jsii.Strings("dynamodb:DescribeTable", "dynamodb:ListTables"),
-		Resources:  jsii.Strings("*"),
-	}))
+type VPCEndpointStack struct {
+	awscdk.Stack
+	Vpc awsec2.IVpc
+	// Endpoints by service name
+	Endpoints           map[string]awsec2.IVpcEndpoint
+	LambdaSecurityGroup awsec2.ISecurityGroup
+}
 
-	secretsManagerEndpoint.AddToPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
-		Principals: &[]awsiam.IPrincipal{awsiam.NewAnyPrincipal()},
-		Actions:    jsii.Strings("secretsmanager:GetSecretValue"),
-		Resources:  jsii.Strings("*"),
-	}))
+func NewVPCEndpointStack(scope constructs.Construct, id string, props *VPCEndpointStackProps) *VPCEndpointStack {
+	self := &VPCEndpointStack{
+		Stack: awscdk.NewStack(scope, &id, &props.StackProps),
+	}
+	stack := self.Stack
+	config := requireConfig(stack, props.Config)
+
+	lambdaCodePath := props.LambdaCodePath
+	if lambdaCodePath == "" {
+		lambdaCodePath = "lambda"
+	}
+
+	vpc := props.existingVpc(stack)
+	if vpc == nil {
+		vpc = newVpc(stack, "MyVPC", awsec2.VpcProps{
+			MaxAzs: jsii.Number(2),
+			SubnetConfiguration: &[]*awsec2.SubnetConfiguration{
+				{
+					CidrMask:   jsii.Number(24),
+					Name:       jsii.String("Public"),
+					SubnetType: awsec2.SubnetType_PUBLIC,
+				},
+				{
+					CidrMask:   jsii.Number(24),
+					Name:       jsii.String("Private"),
+					SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS,
+				},
+				{
+					CidrMask:   jsii.Number(24),
+					Name:       jsii.String("Isolated"),
+					SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED,
+				},
+			},
+		})
+	}
+
+	endpoints := props.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = DefaultEndpoints(props.Resources)
+	}
+
+	var endpointSecurityGroups []awsec2.ISecurityGroup
+	if props.EndpointSecurityGroup != nil {
+		endpointSecurityGroups = []awsec2.ISecurityGroup{props.EndpointSecurityGroup}
+	} else if props.SharedSecurityGroup {
+		endpointSecurityGroups = []awsec2.ISecurityGroup{newEndpointSecurityGroup(stack, vpc, "EndpointSecurityGroup", "Interface endpoints")}
+	}
+
+	self.Endpoints = map[string]awsec2.IVpcEndpoint{}
+	for _, spec := range endpoints {
+		if _, ok := self.Endpoints[spec.Service]; ok {
+			awscdk.Annotations_Of(stack).AddError(jsii.Sprintf("Endpoint %s is declared more than once", spec.Service))
+			continue
+		}
+		if endpoint := createEndpoint(stack, vpc, spec, endpointSecurityGroups); endpoint != nil {
+			self.Endpoints[spec.Service] = endpoint
+		}
+	}
 
 	// Create RDS Instance
-	seconds := float64(64)
-	awsrds.NewDatabaseInstance(stack, jsii.String("MyRDS"), &awsrds.DatabaseInstanceProps{
-		Engine:                    awsrds.DatabaseInstanceEngine_POSTGRES(),
-		InstanceType:              awsec2.InstanceType_Of(awsec2.InstanceClass_BURSTABLE2, awsec2.InstanceSize_MICRO),
-		Vpc:                       vpc,
-		VpcSubnets:                &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED},
-		AllowMajorVersionUpgrade:  jsii.Bool(true),
-		AutoMinorVersionUpgrade:   jsii.Bool(true),
-		RemovalPolicy:             awscdk.RemovalPolicy_DESTROY,
-		StorageEncrypted:          jsii.Bool(true),
-		MonitoringInterval:        awscdk.Duration_Seconds(&seconds),
-		EnablePerformanceInsights: jsii.Bool(true),
-		PubliclyAccessible:        jsii.Bool(false),
-	})
+	isolatedSubnets := &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED}
+	if validSubnetSelection(stack, vpc, isolatedSubnets, "Database") {
+		seconds := float64(60)
+		awsrds.NewDatabaseInstance(stack, jsii.String("MyRDS"), &awsrds.DatabaseInstanceProps{
+			Engine:                    awsrds.DatabaseInstanceEngine_POSTGRES(),
+			InstanceType:              awsec2.InstanceType_Of(awsec2.InstanceClass_BURSTABLE3, awsec2.InstanceSize_MICRO),
+			Vpc:                       vpc,
+			VpcSubnets:                isolatedSubnets,
+			AllowMajorVersionUpgrade:  jsii.Bool(true),
+			AutoMinorVersionUpgrade:   jsii.Bool(true),
+			RemovalPolicy:             awscdk.RemovalPolicy_DESTROY,
+			StorageEncrypted:          jsii.Bool(true),
+			MonitoringInterval:        awscdk.Duration_Seconds(&seconds),
+			EnablePerformanceInsights: jsii.Bool(true),
+			PubliclyAccessible:        jsii.Bool(false),
+		})
+	}
 
 	// Create Lambda Role
 	lambdaRole := awsiam.NewRole(stack, jsii.String("LambdaRole"), &awsiam.RoleProps{
@@ -98,33 +129,56 @@ func NewVPCEndpointStack(scope constructs.Construct, id string, props *VPCEndpoi
 	})
 
 	lambdaRole.AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("service-role/AWSLambdaBasicExecutionRole")))
+	// CDK only adds the ENI permissions to the roles it creates
+	lambdaRole.AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("service-role/AWSLambdaVPCAccessExecutionRole")))
+
+	self.LambdaSecurityGroup = props.LambdaSecurityGroup
+	if self.LambdaSecurityGroup == nil {
+		self.LambdaSecurityGroup = awsec2.NewSecurityGroup(stack, jsii.String("LambdaSecurityGroup"), &awsec2.SecurityGroupProps{
+			Vpc:              vpc,
+			Description:      jsii.String(props.Environment + " VPC endpoint Lambda function"),
+			AllowAllOutbound: jsii.Bool(true),
+		})
+	}
 
 	// Create Lambda Function
-	lambdaFunction := awslambda.NewFunction(stack, jsii.String("MyLambdaFunction"), &awslambda.FunctionProps{
-		Runtime: awslambda.Runtime_NODEJS_20_X(),
-		Handler: jsii.String("index.handler"),
-		Code:    awslambda.Code_FromAsset(jsii.String("lambda"), nil),
-		Vpc:     vpc,
-		Role:    lambdaRole,
-		SecurityGroups: &[]awsec2.ISecurityGroup{
-			awsec2.SecurityGroup_FromSecurityGroupId(stack, jsii.String("lambdaFunctionSG"), jsii.String("sg-exampleID"), &awsec2.SecurityGroupImportOptions{}),
-		},
-	})
-
-	// Allow connections to endpoints from Lambda Function
+	privateSubnets := &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS}
+	if validSubnetSelection(stack, vpc, privateSubnets, "Lambda function") {
+		awslambda.NewFunction(stack, jsii.String("MyLambdaFunction"), &awslambda.FunctionProps{
+			Runtime:        awslambda.Runtime_NODEJS_20_X(),
+			Handler:        jsii.String("index.handler"),
+			Code:           awslambda.Code_FromAsset(jsii.String(lambdaCodePath), nil),
+			Vpc:            vpc,
+			VpcSubnets:     privateSubnets,
+			Role:           lambdaRole,
+			SecurityGroups: &[]awsec2.ISecurityGroup{self.LambdaSecurityGroup},
+		})
+	}
+
+	// Allow HTTPS to the interface endpoints from the Lambda function and the
+	// declared consumers only
+	consumers := append([]awsec2.ISecurityGroup{self.LambdaSecurityGroup}, props.Consumers...)
 	httpsPort := float64(443)
-	snsEndpoint.Connections().AllowFrom(lambdaFunction, awsec2.Port_Tcp(&httpsPort), nil)
-	secretsManagerEndpoint.Connections().AllowFrom(lambdaFunction, awsec2.Port_Tcp(&httpsPort), nil)
-
-	return stack
-}
-
-func main() {
-	app := awscdk.NewApp(nil)
-
-	NewVPCEndpointStack(app, "VPCEndpointStack", &VPCEndpointStackProps{
-		constructs.NewConstruct(nil, nil),
-	})
-
-	app.Synth(nil)
+	allowed := map[string]bool{}
+	for _, spec := range endpoints {
+		endpoint, ok := self.Endpoints[spec.Service].(awsec2.InterfaceVpcEndpoint)
+		if !ok {
+			continue
+		}
+		// A shared group only needs the rules once
+		for _, securityGroup := range *endpoint.Connections().SecurityGroups() {
+			if path := *securityGroup.Node().Path(); !allowed[path] {
+				allowed[path] = true
+				for _, consumer := range consumers {
+					securityGroup.AddIngressRule(consumer, awsec2.Port_Tcp(&httpsPort), nil, nil)
+				}
+			}
+		}
+	}
+
+	ApplyStandardTags(stack, config, props.Environment, "")
+
+	self.Vpc = vpc
+
+	return self
 }
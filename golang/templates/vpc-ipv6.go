@@ -0,0 +1,40 @@
+package templates
+
+import (
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// vpcIpv6Cidr returns the IPv6 block of a dual-stack VPC created in the app
+// and nil for IPv4 only VPCs. Imported VPCs are treated as IPv4 only, their
+// blocks are unknown.
+func vpcIpv6Cidr(vpc awsec2.IVpc) awsec2.CfnVPCCidrBlock {
+	created, ok := vpc.(awsec2.Vpc)
+	if !ok {
+		return nil
+	}
+	// CDK adds the block of IpProtocol_DUAL_STACK VPCs with this id
+	block, _ := created.Node().TryFindChild(jsii.String("ipv6cidr")).(awsec2.CfnVPCCidrBlock)
+	return block
+}
+
+// isDualStack reports whether the VPC has an IPv6 block, see vpcIpv6Cidr.
+func isDualStack(vpc awsec2.IVpc) bool {
+	return vpcIpv6Cidr(vpc) != nil
+}
+
+// allowFromVpc allows the port from the IPv4 block of the VPC and, for
+// dual-stack VPCs, from its IPv6 block.
+func allowFromVpc(securityGroup awsec2.SecurityGroup, vpc awsec2.IVpc, port awsec2.Port, description string) {
+	securityGroup.AddIngressRule(awsec2.Peer_Ipv4(vpc.VpcCidrBlock()), port, jsii.String(description), nil)
+
+	block := vpcIpv6Cidr(vpc)
+	if block == nil {
+		return
+	}
+	ipv6Cidr := awscdk.Fn_Select(jsii.Number(0), vpc.(awsec2.Vpc).VpcIpv6CidrBlocks())
+	securityGroup.AddIngressRule(awsec2.Peer_Ipv6(ipv6Cidr), port, jsii.String(description+" over IPv6"), nil)
+	// The VPC only lists the block once it is associated
+	securityGroup.Node().AddDependency(block)
+}
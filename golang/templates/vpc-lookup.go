@@ -0,0 +1,47 @@
+package templates
+
+import (
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// ExistingVpcProps selects a shared VPC for a template. Vpc takes a VPC of
+// the app, VpcId and VpcName look one up by id or Name tag through the
+// context, which needs the account and region of the stack. When all are
+// empty the template creates its own VPC.
+type ExistingVpcProps struct {
+	Vpc     awsec2.IVpc
+	VpcId   string
+	VpcName string
+}
+
+// existingVpc returns the selected VPC or nil when the template should create
+// one.
+func (props *ExistingVpcProps) existingVpc(scope constructs.Construct) awsec2.IVpc {
+	if props.Vpc != nil {
+		if props.VpcId != "" || props.VpcName != "" {
+			awscdk.Annotations_Of(scope).AddWarning(jsii.String("Vpc is set, VpcId and VpcName are ignored"))
+		}
+		return props.Vpc
+	}
+	if props.VpcId == "" && props.VpcName == "" {
+		return nil
+	}
+
+	stack := awscdk.Stack_Of(scope)
+	if *awscdk.Token_IsUnresolved(stack.Account()) || *awscdk.Token_IsUnresolved(stack.Region()) {
+		awscdk.Annotations_Of(scope).AddError(jsii.String("Looking up a VPC by VpcId or VpcName needs the account and region of the stack"))
+		return nil
+	}
+
+	options := &awsec2.VpcLookupOptions{}
+	if props.VpcId != "" {
+		options.VpcId = jsii.String(props.VpcId)
+	}
+	if props.VpcName != "" {
+		options.VpcName = jsii.String(props.VpcName)
+	}
+	return awsec2.Vpc_FromLookup(scope, jsii.String("Vpc"), options)
+}
@@ -0,0 +1,56 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// newVpc creates the VPC of a template. Subnet groups sharing a name are
+// reported and left out, CDK would fail on the duplicate construct ids
+// instead.
+func newVpc(scope constructs.Construct, id string, props awsec2.VpcProps) awsec2.Vpc {
+	if props.SubnetConfiguration != nil {
+		groups := map[string]awsec2.SubnetType{}
+		var subnets []*awsec2.SubnetConfiguration
+		for _, subnet := range *props.SubnetConfiguration {
+			name := *subnet.Name
+			if subnetType, ok := groups[name]; ok {
+				awscdk.Annotations_Of(scope).AddError(jsii.Sprintf(
+					"VPC %s: subnet group %q is declared for %s and %s subnets, every subnet group needs its own name",
+					id, name, subnetType, subnet.SubnetType))
+				continue
+			}
+			groups[name] = subnet.SubnetType
+			subnets = append(subnets, subnet)
+		}
+		props.SubnetConfiguration = &subnets
+	}
+
+	return awsec2.NewVpc(scope, jsii.String(id), &props)
+}
+
+// validSubnetSelection reports a selection that matches no subnet of the VPC,
+// e.g. isolated subnets in a VPC with public and private subnets only, as an
+// error of scope. The resource using the selection should be left out when it
+// returns false, CDK fails on it otherwise.
+func validSubnetSelection(scope constructs.Construct, vpc awsec2.IVpc, selection *awsec2.SubnetSelection, purpose string) (valid bool) {
+	defer func() {
+		// SelectSubnets throws when the VPC has no such subnet group
+		if err := recover(); err != nil {
+			message := strings.TrimPrefix(fmt.Sprint(err), "Error: ")
+			awscdk.Annotations_Of(scope).AddError(jsii.Sprintf("%s: %s", purpose, message))
+			valid = false
+		}
+	}()
+
+	if len(*vpc.SelectSubnets(selection).SubnetIds) == 0 {
+		awscdk.Annotations_Of(scope).AddError(jsii.Sprintf("%s: the subnet selection matches no subnets of the VPC", purpose))
+		return false
+	}
+	return true
+}
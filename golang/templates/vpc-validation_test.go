@@ -0,0 +1,65 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func newValidationStack() awscdk.Stack {
+	return awscdk.NewStack(awscdk.NewApp(nil), jsii.String("validation"), &awscdk.StackProps{Env: testEnvironment})
+}
+
+func TestNewVpcDuplicateSubnetGroup(t *testing.T) {
+	stack := newValidationStack()
+	newVpc(stack, "Vpc", awsec2.VpcProps{
+		MaxAzs: jsii.Number(2),
+		SubnetConfiguration: &[]*awsec2.SubnetConfiguration{
+			{Name: jsii.String("Public"), SubnetType: awsec2.SubnetType_PUBLIC},
+			{Name: jsii.String("App"), SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS},
+			{Name: jsii.String("App"), SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED},
+		},
+	})
+
+	assertAnnotationErrors(t, stack, `/validation: VPC Vpc: subnet group "App" is declared for PRIVATE_WITH_EGRESS and PRIVATE_ISOLATED subnets, every subnet group needs its own name`)
+	// The duplicate is left out instead of failing synth
+	assertions.Template_FromStack(stack, nil).ResourceCountIs(jsii.String("AWS::EC2::Subnet"), jsii.Number(4))
+}
+
+func TestValidSubnetSelection(t *testing.T) {
+	tests := []struct {
+		name      string
+		selection *awsec2.SubnetSelection
+		wants     []string
+	}{
+		{"private subnets", &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS}, nil},
+		{"missing subnet type", &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED},
+			[]string{"/validation: Cache: There are no 'Isolated' subnet groups in this VPC"}},
+		{"missing subnet group", &awsec2.SubnetSelection{SubnetGroupName: jsii.String("Data")},
+			[]string{"/validation: Cache: There are no subnet groups with name 'Data' in this VPC"}},
+		{"empty selection", &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS, AvailabilityZones: jsii.Strings("us-east-1f")},
+			[]string{"/validation: Cache: the subnet selection matches no subnets of the VPC"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stack := newValidationStack()
+			vpc := awsec2.NewVpc(stack, jsii.String("Vpc"), &awsec2.VpcProps{
+				MaxAzs: jsii.Number(2),
+				SubnetConfiguration: &[]*awsec2.SubnetConfiguration{
+					{Name: jsii.String("Public"), SubnetType: awsec2.SubnetType_PUBLIC},
+					{Name: jsii.String("Private"), SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS},
+				},
+			})
+
+			valid := validSubnetSelection(stack, vpc, test.selection, "Cache")
+			if valid != (len(test.wants) == 0) {
+				t.Errorf("validSubnetSelection() = %v, want %v", valid, len(test.wants) == 0)
+			}
+			assertAnnotationErrors(t, stack, test.wants...)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package templates
+
+import (
+	"path/filepath"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigatewayv2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigatewayv2integrations"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53targets"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"cdk-template/naming"
+)
+
+type PropsWebSocketAPI struct {
+	awscdk.StackProps
+	DomainName     string
+	Environment    string
+	CertificateArn string
+	HostedZoneId   string
+	ApiDomainName  string
+	IsProduction   bool
+	Config         *Config
+	// StageName defaults to Environment.
+	StageName string
+}
+
+type WebSocketAPI struct {
+	awscdk.Stack
+	ConnectionsTable awsdynamodb.ITable
+	Stage            awsapigatewayv2.WebSocketStage
+}
+
+// Attribute holding the epoch second after which DynamoDB expires a connection
+const connectionsTableTTLAttribute = "expiresAt"
+
+func NewWebSocketAPI(scope constructs.Construct, id string, props *PropsWebSocketAPI) *WebSocketAPI {
+	self := &WebSocketAPI{
+		Stack: awscdk.NewStack(scope, &id, &props.StackProps),
+	}
+	config := requireConfig(self.Stack, props.Config)
+	names := naming.New(props.Environment, config.Project)
+
+	removalPolicy := awscdk.RemovalPolicy_DESTROY
+	if props.IsProduction {
+		removalPolicy = awscdk.RemovalPolicy_RETAIN
+	}
+
+	connectionsTable := awsdynamodb.NewTable(self.Stack, jsii.String("connections"+props.DomainName), &awsdynamodb.TableProps{
+		TableName:           jsii.String(names.Name(naming.DynamoDBTable, "websocket-connections")),
+		PartitionKey:        &awsdynamodb.Attribute{Name: jsii.String("connectionId"), Type: awsdynamodb.AttributeType_STRING},
+		BillingMode:         awsdynamodb.BillingMode_PAY_PER_REQUEST,
+		TimeToLiveAttribute: jsii.String(connectionsTableTTLAttribute),
+		Encryption:          awsdynamodb.TableEncryption_AWS_MANAGED,
+		PointInTimeRecovery: jsii.Bool(props.IsProduction),
+		RemovalPolicy:       removalPolicy,
+	})
+
+	connectFunction := self.createRouteFunction(props, names, connectionsTable, "connect")
+	disconnectFunction := self.createRouteFunction(props, names, connectionsTable, "disconnect")
+	defaultFunction := self.createRouteFunction(props, names, connectionsTable, "default")
+
+	api := awsapigatewayv2.NewWebSocketApi(self.Stack, &props.ApiDomainName, &awsapigatewayv2.WebSocketApiProps{
+		ApiName:                  jsii.String(props.ApiDomainName),
+		Description:              jsii.String(props.ApiDomainName + " WebSocket API for the " + props.Environment + " environment"),
+		RouteSelectionExpression: jsii.String("$request.body.action"),
+		ConnectRouteOptions: &awsapigatewayv2.WebSocketRouteOptions{
+			Integration: awsapigatewayv2integrations.NewWebSocketLambdaIntegration(jsii.String("ConnectIntegration"), connectFunction),
+		},
+		DisconnectRouteOptions: &awsapigatewayv2.WebSocketRouteOptions{
+			Integration: awsapigatewayv2integrations.NewWebSocketLambdaIntegration(jsii.String("DisconnectIntegration"), disconnectFunction),
+		},
+		DefaultRouteOptions: &awsapigatewayv2.WebSocketRouteOptions{
+			Integration: awsapigatewayv2integrations.NewWebSocketLambdaIntegration(jsii.String("DefaultIntegration"), defaultFunction),
+		},
+	})
+
+	certificate := awscertificatemanager.Certificate_FromCertificateArn(self.Stack, jsii.Sprintf("%sCertificate", props.ApiDomainName), &props.CertificateArn)
+
+	apiGatewayDomainName := awsapigatewayv2.NewDomainName(self.Stack, jsii.Sprintf("%sApiGatewayDomainName", props.ApiDomainName), &awsapigatewayv2.DomainNameProps{
+		DomainName:  &props.ApiDomainName,
+		Certificate: certificate,
+	})
+
+	stageName := props.StageName
+	if stageName == "" {
+		stageName = props.Environment
+	}
+
+	stage := awsapigatewayv2.NewWebSocketStage(self.Stack, jsii.String(props.DomainName+"Stage"), &awsapigatewayv2.WebSocketStageProps{
+		WebSocketApi: api,
+		StageName:    jsii.String(stageName),
+		AutoDeploy:   jsii.Bool(true),
+		DomainMapping: &awsapigatewayv2.DomainMappingOptions{
+			DomainName: apiGatewayDomainName,
+		},
+		Throttle: &awsapigatewayv2.ThrottleSettings{
+			RateLimit:  jsii.Number(2000),
+			BurstLimit: jsii.Number(1000),
+		},
+	})
+
+	// Every route handler may need to push messages back to connected clients
+	for _, function := range []awslambda.Function{connectFunction, disconnectFunction, defaultFunction} {
+		stage.GrantManagementApiAccess(function)
+		function.AddEnvironment(jsii.String("CALLBACK_URL"), stage.CallbackUrl(), nil)
+	}
+
+	hostedZone := awsroute53.HostedZone_FromHostedZoneAttributes(self.Stack, jsii.String("HZA"+props.ApiDomainName), &awsroute53.HostedZoneAttributes{
+		HostedZoneId: &props.HostedZoneId,
+		ZoneName:     &props.ApiDomainName,
+	})
+
+	// Create a A record in Route 53 for the custom domain name
+	awsroute53.NewARecord(self.Stack, jsii.String("ARecord"+props.ApiDomainName), &awsroute53.ARecordProps{
+		Zone:           hostedZone,
+		RecordName:     &props.ApiDomainName,
+		Target:         awsroute53.RecordTarget_FromAlias(awsroute53targets.NewApiGatewayv2DomainProperties(apiGatewayDomainName.RegionalDomainName(), apiGatewayDomainName.RegionalHostedZoneId())),
+		Comment:        jsii.String("WebSocket API alias record for " + props.DomainName),
+		DeleteExisting: jsii.Bool(true),
+	})
+
+	ApplyStandardTags(self.Stack, config, props.Environment, props.ApiDomainName)
+
+	self.ConnectionsTable = connectionsTable
+	self.Stage = stage
+
+	return self
+}
+
+// createRouteFunction creates the handler for the $connect, $disconnect or $default route.
+func (self *WebSocketAPI) createRouteFunction(props *PropsWebSocketAPI, names naming.Namer, connectionsTable awsdynamodb.ITable, route string) awslambda.Function {
+	golangCodeAsset := "sample-code/golang-sample.zip"
+	dir := filepath.Dir(golangCodeAsset)
+	seconds := float64(10)
+
+	function := awslambda.NewFunction(self.Stack, jsii.String("lambda"+route+props.DomainName), &awslambda.FunctionProps{
+		Runtime:    awslambda.Runtime_PROVIDED_AL2(),
+		Handler:    jsii.String("bootstrap"),
+		Code:       awslambda.Code_FromAsset(&dir, nil),
+		MemorySize: jsii.Number(256),
+		Timeout:    awscdk.Duration_Seconds(&seconds),
+		Environment: &map[string]*string{
+			"CONNECTIONS_TABLE_NAME": connectionsTable.TableName(),
+			"CONNECTION_TTL_FIELD":   jsii.String(connectionsTableTTLAttribute),
+			"ROUTE":                  jsii.String("$" + route),
+		},
+		Description:  jsii.String(props.Environment + " Lambda Function for the WebSocket $" + route + " route"),
+		FunctionName: jsii.String(names.Name(naming.LambdaFunction, "websocket", route)),
+	})
+
+	connectionsTable.GrantReadWriteData(function)
+
+	return function
+}